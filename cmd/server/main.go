@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -13,6 +16,7 @@ import (
 
 	"github.com/yeoboseyo/server/internal/db"
 	"github.com/yeoboseyo/server/internal/httpapi"
+	"github.com/yeoboseyo/server/internal/jwtkeys"
 )
 
 func main() {
@@ -20,6 +24,13 @@ func main() {
 
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
+	// Ключи подписи JWT: без настоящего ключа в production не стартуем
+	keys, err := jwtkeys.FromEnv()
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("failed to load jwt signing keys")
+	}
+	httpapi.SetKeySet(keys)
+
 	// Инициализируем подключение к PostgreSQL перед запуском HTTP-сервера.
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -46,11 +57,33 @@ func main() {
 	r.Use(corsMiddleware)
 
 	addr := ":" + getEnv("PORT", "8080")
+	srv := &http.Server{Addr: addr, Handler: r}
+	// Shutdown не ждёт захваченные WebSocket-соединения — закрываем их сами с 1001
+	srv.RegisterOnShutdown(httpapi.CloseSockets)
+
+	// По SIGINT/SIGTERM перестаём принимать запросы и даём текущим завершиться
+	stop, stopCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopCancel()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-stop.Done()
+		zlog.Info().Msg("shutting down server")
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			zlog.Error().Err(err).Msg("graceful shutdown failed")
+		}
+	}()
+
 	zlog.Info().Msgf("starting server on %s", addr)
 
-	if err := http.ListenAndServe(addr, r); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		zlog.Fatal().Err(err).Msg("server failed")
 	}
+	<-shutdownDone
 }
 
 func getEnv(key, def string) string {
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// CreateBot создаёт бот-аккаунт, принадлежащий пользователю ownerID
+func CreateBot(ctx context.Context, pool *pgxpool.Pool, ownerID int64, name, picture string) (*models.User, error) {
+	query := `
+		INSERT INTO users (email, name, picture, is_bot, bot_owner_id, created_at, updated_at)
+		VALUES ('', $2, $3, TRUE, $1, NOW(), NOW())
+		RETURNING ` + userColumns
+
+	user, err := scanUser(pool.QueryRow(ctx, query, ownerID, name, picture))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+
+	return user, nil
+}
+
+// ListBots отдаёт ботов пользователя
+func ListBots(ctx context.Context, pool *pgxpool.Pool, ownerID int64) ([]models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE bot_owner_id = $1 ORDER BY id`
+
+	rows, err := pool.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+	defer rows.Close()
+
+	bots := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bot: %w", err)
+		}
+		bots = append(bots, *u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+
+	return bots, nil
+}
+
+// GetBot находит бота пользователя ownerID (nil — нет такого бота у этого владельца)
+func GetBot(ctx context.Context, pool *pgxpool.Pool, ownerID, botID int64) (*models.User, error) {
+	user, err := getUserByID(ctx, pool, botID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsBot || user.BotOwnerID == nil || *user.BotOwnerID != ownerID {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// DeleteBot удаляет бота вместе с его токенами. false — у владельца нет такого бота.
+func DeleteBot(ctx context.Context, pool *pgxpool.Pool, ownerID, botID int64) (bool, error) {
+	tag, err := pool.Exec(ctx, `DELETE FROM users WHERE id = $2 AND is_bot AND bot_owner_id = $1`, ownerID, botID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete bot: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,362 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const conversationColumns = `id, kind, direct_key, title, avatar, created_by, pinned_message_id, created_at, last_message_id, last_message_at`
+
+func scanConversation(row pgx.Row) (*models.Conversation, error) {
+	var c models.Conversation
+	err := row.Scan(
+		&c.ID,
+		&c.Kind,
+		&c.DirectKey,
+		&c.Title,
+		&c.Avatar,
+		&c.CreatedBy,
+		&c.PinnedMessageID,
+		&c.CreatedAt,
+		&c.LastMessageID,
+		&c.LastMessageAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// directKey — ключ личной переписки пары пользователей (не зависит от порядка)
+func directKey(a, b int64) string {
+	return fmt.Sprintf("%d:%d", min(a, b), max(a, b))
+}
+
+// getOrCreateDirectConversation находит личную переписку пары или создаёт её с обоими участниками
+func getOrCreateDirectConversation(ctx context.Context, q querier, a, b int64) (*models.Conversation, error) {
+	key := directKey(a, b)
+
+	c, err := scanConversation(q.QueryRow(ctx, `
+		INSERT INTO conversations (kind, direct_key, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (direct_key) DO NOTHING
+		RETURNING `+conversationColumns,
+		models.ConversationDirect, key,
+	))
+	if err == nil {
+		if _, err := q.Exec(ctx, `
+			INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+			VALUES ($1, $2, NOW()), ($1, $3, NOW())
+		`, c.ID, a, b); err != nil {
+			return nil, fmt.Errorf("failed to add conversation members: %w", err)
+		}
+		return c, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	// Переписка уже есть (возможно, её только что создал параллельный запрос)
+	c, err = scanConversation(q.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE direct_key = $1`, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	return c, nil
+}
+
+// mergeConversations переносит переписки дубликата (secondaryID) на основной аккаунт.
+// Вызывается из MergeUsers внутри её транзакции.
+func mergeConversations(ctx context.Context, tx pgx.Tx, primaryID, secondaryID int64) error {
+	// Личная переписка между двумя аккаунтами одного человека теряет смысл
+	if _, err := tx.Exec(ctx, `DELETE FROM conversations WHERE direct_key = $1`, directKey(primaryID, secondaryID)); err != nil {
+		return fmt.Errorf("failed to delete conversation between merged users: %w", err)
+	}
+
+	// Если с собеседником дубликата у основного аккаунта уже есть личная переписка,
+	// сообщения переносим в неё, а переписку дубликата удаляем
+	rows, err := tx.Query(ctx, `
+		SELECT cs.id, cp.id
+		FROM conversation_members ms
+		JOIN conversations cs ON cs.id = ms.conversation_id AND cs.kind = $3
+		JOIN conversation_members partner ON partner.conversation_id = cs.id AND partner.user_id <> $2
+		JOIN conversations cp ON cp.direct_key = LEAST($1, partner.user_id)::text || ':' || GREATEST($1, partner.user_id)::text
+		WHERE ms.user_id = $2
+	`, primaryID, secondaryID, models.ConversationDirect)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate conversations: %w", err)
+	}
+	type pair struct{ src, dst int64 }
+	pairs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pair, error) {
+		var p pair
+		err := row.Scan(&p.src, &p.dst)
+		return p, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate conversations: %w", err)
+	}
+
+	for _, p := range pairs {
+		if _, err := tx.Exec(ctx, `UPDATE messages SET conversation_id = $2 WHERE conversation_id = $1`, p.src, p.dst); err != nil {
+			return fmt.Errorf("failed to move messages: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM conversations WHERE id = $1`, p.src); err != nil {
+			return fmt.Errorf("failed to delete duplicate conversation: %w", err)
+		}
+		if err := refreshLastMessage(ctx, tx, p.dst); err != nil {
+			return err
+		}
+	}
+
+	// Остальные переписки дубликата просто переходят к основному аккаунту
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversation_members SET user_id = $1
+		WHERE user_id = $2
+		  AND conversation_id NOT IN (SELECT conversation_id FROM conversation_members WHERE user_id = $1)
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move conversation members: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversations c
+		SET direct_key = (
+			SELECT MIN(m.user_id)::text || ':' || MAX(m.user_id)::text
+			FROM conversation_members m WHERE m.conversation_id = c.id
+		)
+		WHERE c.kind = $2
+		  AND c.id IN (SELECT conversation_id FROM conversation_members WHERE user_id = $1)
+	`, primaryID, models.ConversationDirect); err != nil {
+		return fmt.Errorf("failed to update direct keys: %w", err)
+	}
+
+	// В группах, где состояли оба аккаунта, основному остаётся старшая из двух ролей
+	// (строка дубликата удалится вместе с ним, так что владелец остаётся один)
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversation_members p SET role = s.role
+		FROM conversation_members s
+		WHERE p.user_id = $1 AND s.user_id = $2 AND s.conversation_id = p.conversation_id
+		  AND `+roleRankSQL("s.role")+` > `+roleRankSQL("p.role")+`
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to merge member roles: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE conversations SET created_by = $1 WHERE created_by = $2`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move group creator: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE messages SET sender_id = $1 WHERE sender_id = $2`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move messages: %w", err)
+	}
+
+	return nil
+}
+
+// refreshLastMessage пересчитывает последнее сообщение переписки
+func refreshLastMessage(ctx context.Context, q querier, conversationID int64) error {
+	_, err := q.Exec(ctx, `
+		UPDATE conversations c
+		SET last_message_id = m.id, last_message_at = m.created_at
+		FROM (
+			SELECT id, created_at FROM messages
+			WHERE conversation_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) m
+		WHERE c.id = $1
+	`, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh last message: %w", err)
+	}
+	return nil
+}
+
+// Cursor — позиция для keyset-пагинации по (время, id)
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// GetConversation отдаёт переписку по id (nil, если её нет)
+func GetConversation(ctx context.Context, pool *pgxpool.Pool, conversationID int64) (*models.Conversation, error) {
+	c, err := scanConversation(pool.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = $1`, conversationID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return c, nil
+}
+
+// IsConversationMember проверяет, что пользователь участвует в переписке
+func IsConversationMember(ctx context.Context, pool *pgxpool.Pool, conversationID, userID int64) (bool, error) {
+	var ok bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = $1 AND user_id = $2)
+	`, conversationID, userID).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("failed to check conversation member: %w", err)
+	}
+	return ok, nil
+}
+
+// ListConversationMemberIDs отдаёт id участников переписки
+func ListConversationMemberIDs(ctx context.Context, pool *pgxpool.Pool, conversationID int64) ([]int64, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT user_id FROM conversation_members WHERE conversation_id = $1
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation members: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation member: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list conversation members: %w", err)
+	}
+	return ids, nil
+}
+
+// ListConversations отдаёт переписки пользователя по убыванию последней активности,
+// с последним сообщением, собеседником (для личных) и числом непрочитанных.
+// before — курсор последней переписки предыдущей страницы (nil — с начала).
+func ListConversations(ctx context.Context, pool *pgxpool.Pool, userID int64, before *Cursor, limit int) ([]models.ConversationSummary, error) {
+	args := []any{userID, limit}
+	cond := ""
+	if before != nil {
+		cond = `AND (COALESCE(c.last_message_at, c.created_at), c.id) < ($3, $4)`
+		args = append(args, before.Time, before.ID)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT c.id, c.kind, c.direct_key, c.title, c.avatar, c.created_by, c.pinned_message_id,
+		       c.created_at, c.last_message_id, c.last_message_at, cm.role,
+		       lm.id, lm.conversation_id, lm.sender_id, lm.kind, lm.content, lm.system, lm.created_at,
+		       lm.edited_at, lm.deleted_at, h.hidden_at,
+		       (
+		           SELECT COUNT(*) FROM messages m
+		           WHERE m.conversation_id = c.id AND m.id > cm.last_read_message_id
+		             AND m.sender_id <> $1 AND m.kind = 'text' AND m.deleted_at IS NULL
+		       )
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		LEFT JOIN messages lm ON lm.id = c.last_message_id
+		LEFT JOIN message_hidden h ON h.message_id = lm.id AND h.user_id = $1
+		WHERE cm.user_id = $1 `+cond+`
+		ORDER BY COALESCE(c.last_message_at, c.created_at) DESC, c.id DESC
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.ConversationSummary{}
+	for rows.Next() {
+		var s models.ConversationSummary
+		var lm struct {
+			ID             *int64
+			ConversationID *int64
+			SenderID       *int64
+			Kind           *string
+			Content        *string
+			System         *models.SystemEvent
+			CreatedAt      *time.Time
+			EditedAt       *time.Time
+			DeletedAt      *time.Time
+			HiddenAt       *time.Time
+		}
+		err := rows.Scan(
+			&s.ID, &s.Kind, &s.DirectKey, &s.Title, &s.Avatar, &s.CreatedBy, &s.PinnedMessageID,
+			&s.CreatedAt, &s.LastMessageID, &s.LastMessageAt, &s.Role,
+			&lm.ID, &lm.ConversationID, &lm.SenderID, &lm.Kind, &lm.Content, &lm.System, &lm.CreatedAt,
+			&lm.EditedAt, &lm.DeletedAt, &lm.HiddenAt,
+			&s.UnreadCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		if lm.ID != nil {
+			s.LastMessage = &models.Message{
+				ID:             *lm.ID,
+				ConversationID: *lm.ConversationID,
+				SenderID:       *lm.SenderID,
+				Kind:           *lm.Kind,
+				Content:        *lm.Content,
+				System:         lm.System,
+				CreatedAt:      *lm.CreatedAt,
+				EditedAt:       lm.EditedAt,
+			}
+			switch {
+			case lm.DeletedAt != nil:
+				s.LastMessage.Tombstone(models.DeletedForEveryone, *lm.DeletedAt)
+			case lm.HiddenAt != nil:
+				s.LastMessage.Tombstone(models.DeletedForMe, *lm.HiddenAt)
+			}
+		}
+		list = append(list, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+
+	if err := attachPeers(ctx, pool, userID, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// attachPeers проставляет собеседников в личных переписках
+func attachPeers(ctx context.Context, pool *pgxpool.Pool, userID int64, list []models.ConversationSummary) error {
+	var ids []int64
+	for _, s := range list {
+		if s.Kind == models.ConversationDirect {
+			ids = append(ids, s.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT cm.conversation_id, u.id, u.name, u.picture, u.is_bot
+		FROM conversation_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.conversation_id = ANY($1) AND cm.user_id <> $2
+	`, ids, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list conversation peers: %w", err)
+	}
+	defer rows.Close()
+
+	peers := make(map[int64]*models.UserProfile)
+	for rows.Next() {
+		var convID int64
+		var p models.UserProfile
+		if err := rows.Scan(&convID, &p.ID, &p.Name, &p.Picture, &p.IsBot); err != nil {
+			return fmt.Errorf("failed to scan conversation peer: %w", err)
+		}
+		peers[convID] = &p
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list conversation peers: %w", err)
+	}
+
+	for i := range list {
+		list[i].Peer = peers[list[i].ID]
+	}
+
+	return nil
+}
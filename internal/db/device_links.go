@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const deviceLinkColumns = `id, client_name, user_agent, ip, created_at, expires_at, approved_by, approved_at, consumed_at`
+
+func scanDeviceLink(row pgx.Row) (*models.DeviceLink, error) {
+	var l models.DeviceLink
+	err := row.Scan(
+		&l.ID,
+		&l.ClientName,
+		&l.UserAgent,
+		&l.IP,
+		&l.CreatedAt,
+		&l.ExpiresAt,
+		&l.ApprovedBy,
+		&l.ApprovedAt,
+		&l.ConsumedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// CreateDeviceLink сохраняет запрос нового устройства на вход
+func CreateDeviceLink(ctx context.Context, pool *pgxpool.Pool, codeHash, waitTokenHash, clientName, userAgent, ip string, expiresAt time.Time) (*models.DeviceLink, error) {
+	query := `
+		INSERT INTO device_links (code_hash, wait_token_hash, client_name, user_agent, ip, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
+		RETURNING ` + deviceLinkColumns
+
+	l, err := scanDeviceLink(pool.QueryRow(ctx, query, codeHash, waitTokenHash, clientName, userAgent, ip, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device link: %w", err)
+	}
+
+	return l, nil
+}
+
+// GetDeviceLinkByWaitToken находит запрос по хешу wait-токена (nil — не найден)
+func GetDeviceLinkByWaitToken(ctx context.Context, pool *pgxpool.Pool, waitTokenHash string) (*models.DeviceLink, error) {
+	query := `SELECT ` + deviceLinkColumns + ` FROM device_links WHERE wait_token_hash = $1`
+
+	l, err := scanDeviceLink(pool.QueryRow(ctx, query, waitTokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get device link: %w", err)
+	}
+
+	return l, nil
+}
+
+// ApproveDeviceLink подтверждает вход по коду из QR от имени userID.
+// Код одноразовый: nil — код не найден, истёк или уже подтверждён.
+func ApproveDeviceLink(ctx context.Context, pool *pgxpool.Pool, codeHash string, userID int64) (*models.DeviceLink, error) {
+	query := `
+		UPDATE device_links SET approved_by = $2, approved_at = NOW()
+		WHERE code_hash = $1 AND approved_at IS NULL AND expires_at > NOW()
+		RETURNING ` + deviceLinkColumns
+
+	l, err := scanDeviceLink(pool.QueryRow(ctx, query, codeHash, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to approve device link: %w", err)
+	}
+
+	return l, nil
+}
+
+// ConsumeDeviceLink забирает подтверждённый вход (один раз) и возвращает ID пользователя.
+// 0 — вход ещё не подтверждён, истёк или уже забран.
+func ConsumeDeviceLink(ctx context.Context, pool *pgxpool.Pool, linkID int64) (int64, error) {
+	var userID int64
+	err := pool.QueryRow(ctx, `
+		UPDATE device_links SET consumed_at = NOW()
+		WHERE id = $1 AND approved_at IS NOT NULL AND consumed_at IS NULL AND expires_at > NOW()
+		RETURNING approved_by
+	`, linkID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to consume device link: %w", err)
+	}
+
+	return userID, nil
+}
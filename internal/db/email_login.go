@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateEmailLoginToken сохраняет хеши новой ссылки и кода для входа по email.
+// Предыдущие неиспользованные ссылки на этот email перестают действовать.
+func CreateEmailLoginToken(ctx context.Context, pool *pgxpool.Pool, email, tokenHash, codeHash, ip string, expiresAt time.Time) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE email_login_tokens SET consumed_at = NOW()
+		WHERE email = $1 AND consumed_at IS NULL
+	`, email); err != nil {
+		return fmt.Errorf("failed to invalidate email login tokens: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO email_login_tokens (email, token_hash, code_hash, ip, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+	`, email, tokenHash, codeHash, ip, expiresAt); err != nil {
+		return fmt.Errorf("failed to create email login token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeEmailLoginToken погашает ссылку по хешу и возвращает email ("" — ссылка
+// не найдена, истекла или уже использована).
+func ConsumeEmailLoginToken(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (string, error) {
+	var email string
+	err := pool.QueryRow(ctx, `
+		UPDATE email_login_tokens SET consumed_at = NOW()
+		WHERE token_hash = $1 AND consumed_at IS NULL AND expires_at > NOW()
+		RETURNING email
+	`, tokenHash).Scan(&email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to consume email login token: %w", err)
+	}
+
+	return email, nil
+}
+
+// ConsumeEmailLoginCode сверяет код с последним активным кодом для email и погашает его.
+// Каждая неудачная попытка учитывается; после maxAttempts код перестаёт действовать.
+func ConsumeEmailLoginCode(ctx context.Context, pool *pgxpool.Pool, email, codeHash string, maxAttempts int) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	var storedHash string
+	var attempts int
+	err = tx.QueryRow(ctx, `
+		SELECT id, code_hash, attempts
+		FROM email_login_tokens
+		WHERE email = $1 AND consumed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`, email).Scan(&id, &storedHash, &attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get email login code: %w", err)
+	}
+
+	ok := subtle.ConstantTimeCompare([]byte(storedHash), []byte(codeHash)) == 1
+	if ok {
+		_, err = tx.Exec(ctx, `UPDATE email_login_tokens SET consumed_at = NOW() WHERE id = $1`, id)
+	} else {
+		_, err = tx.Exec(ctx, `
+			UPDATE email_login_tokens
+			SET attempts = attempts + 1,
+			    consumed_at = CASE WHEN attempts + 1 >= $2 THEN NOW() ELSE consumed_at END
+			WHERE id = $1
+		`, id, maxAttempts)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update email login code: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return ok, nil
+}
@@ -0,0 +1,576 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Ошибки управления группой
+var (
+	ErrNotGroupMember = errors.New("not a group member") // группы нет или пользователь в ней не состоит
+	ErrGroupForbidden = errors.New("not enough rights in group")
+	ErrGroupFull      = errors.New("group member limit reached")
+	ErrNoSuchMessage  = errors.New("message not found in conversation")
+)
+
+// PostedMessage — сохранённое сообщение и его seq в лентах участников
+type PostedMessage struct {
+	Message *models.Message
+	Seqs    map[int64]int64
+}
+
+// roleRankSQL — models.RoleRank для колонки с ролью
+func roleRankSQL(col string) string {
+	return `CASE ` + col + ` WHEN 'owner' THEN 3 WHEN 'admin' THEN 2 ELSE 1 END`
+}
+
+// userNames отдаёт имена пользователей по id (для текста служебных сообщений)
+func userNames(ctx context.Context, q querier, ids []int64) (map[int64]string, error) {
+	rows, err := q.Query(ctx, `SELECT id, name FROM users WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user names: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int64]string, len(ids))
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan user name: %w", err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user names: %w", err)
+	}
+	return names, nil
+}
+
+// postSystem пишет служебное сообщение в группу и добавляет его к posted
+func postSystem(ctx context.Context, q querier, conversationID int64, event *models.SystemEvent, posted []PostedMessage) ([]PostedMessage, error) {
+	msg, seqs, err := createSystemMessage(ctx, q, conversationID, event)
+	if err != nil {
+		return nil, err
+	}
+	return append(posted, PostedMessage{Message: msg, Seqs: seqs}), nil
+}
+
+// lockGroup блокирует группу до конца транзакции (изменения одной группы идут
+// по очереди) и отдаёт роль пользователя в ней
+func lockGroup(ctx context.Context, tx pgx.Tx, conversationID, userID int64) (string, error) {
+	var role string
+	err := tx.QueryRow(ctx, `
+		SELECT cm.role FROM conversations c
+		JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = $2
+		WHERE c.id = $1 AND c.kind = $3
+		FOR UPDATE OF c
+	`, conversationID, userID, models.ConversationGroup).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotGroupMember
+		}
+		return "", fmt.Errorf("failed to lock group: %w", err)
+	}
+	return role, nil
+}
+
+// requireGroupRole проверяет, что у пользователя в группе роль не ниже minRole
+func requireGroupRole(ctx context.Context, q querier, conversationID, userID int64, minRole string) error {
+	var role string
+	err := q.QueryRow(ctx, `
+		SELECT cm.role FROM conversations c
+		JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = $2
+		WHERE c.id = $1 AND c.kind = $3
+	`, conversationID, userID, models.ConversationGroup).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotGroupMember
+		}
+		return fmt.Errorf("failed to get member role: %w", err)
+	}
+	if models.RoleRank(role) < models.RoleRank(minRole) {
+		return ErrGroupForbidden
+	}
+	return nil
+}
+
+// memberRole отдаёт роль участника; ErrNotGroupMember — если он не в группе
+func memberRole(ctx context.Context, q querier, conversationID, userID int64) (string, error) {
+	var role string
+	err := q.QueryRow(ctx, `
+		SELECT role FROM conversation_members WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotGroupMember
+		}
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+	return role, nil
+}
+
+// addMembers добавляет в группу существующих пользователей, которых в ней ещё нет,
+// и отдаёт добавленных. ErrGroupFull — если участников станет больше maxMembers.
+// Прежняя история считается прочитанной: новичку не нужны счётчик непрочитанного
+// на всю историю и отметки о прочтении чужих старых сообщений.
+func addMembers(ctx context.Context, q querier, conversationID int64, userIDs []int64, maxMembers int) ([]int64, error) {
+	rows, err := q.Query(ctx, `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at, last_read_message_id)
+		SELECT $1, id, $3, NOW(), (SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1)
+		FROM users WHERE id = ANY($2)
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`, conversationID, userIDs, models.RoleMember)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add group members: %w", err)
+	}
+	added, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to add group members: %w", err)
+	}
+
+	var count int
+	if err := q.QueryRow(ctx, `
+		SELECT COUNT(*) FROM conversation_members WHERE conversation_id = $1
+	`, conversationID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count group members: %w", err)
+	}
+	if count > maxMembers {
+		return nil, ErrGroupFull
+	}
+
+	slices.Sort(added)
+	return added, nil
+}
+
+// CreateGroup создаёт группу, где ownerID — владелец, и добавляет в неё memberIDs
+// (несуществующие id пропускаются). Отдаёт группу и служебные сообщения о создании.
+func CreateGroup(ctx context.Context, pool *pgxpool.Pool, ownerID int64, title string, avatar *string, memberIDs []int64, maxMembers int) (*models.Conversation, []PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := scanConversation(tx.QueryRow(ctx, `
+		INSERT INTO conversations (kind, title, avatar, created_by, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING `+conversationColumns,
+		models.ConversationGroup, title, avatar, ownerID,
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, NOW())
+	`, c.ID, ownerID, models.RoleOwner); err != nil {
+		return nil, nil, fmt.Errorf("failed to add group owner: %w", err)
+	}
+
+	posted, err := postSystem(ctx, tx, c.ID, &models.SystemEvent{
+		Action:  models.SystemGroupCreated,
+		ActorID: ownerID,
+		Title:   title,
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	added, err := addMembers(ctx, tx, c.ID, slices.DeleteFunc(slices.Clone(memberIDs), func(id int64) bool { return id == ownerID }), maxMembers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(added) > 0 {
+		posted, err = postSystem(ctx, tx, c.ID, &models.SystemEvent{
+			Action:  models.SystemMembersAdded,
+			ActorID: ownerID,
+			UserIDs: added,
+		}, posted)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Последнее сообщение сдвинулось — перечитываем группу
+	c, err = scanConversation(tx.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = $1`, c.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return c, posted, nil
+}
+
+// AddGroupMembers добавляет участников (нужна роль admin или owner).
+// Уже состоящие в группе и несуществующие id пропускаются.
+func AddGroupMembers(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID int64, userIDs []int64, maxMembers int) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if models.RoleRank(role) < models.RoleRank(models.RoleAdmin) {
+		return nil, ErrGroupForbidden
+	}
+
+	added, err := addMembers(ctx, tx, conversationID, userIDs, maxMembers)
+	if err != nil {
+		return nil, err
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	posted, err := postSystem(ctx, tx, conversationID, &models.SystemEvent{
+		Action:  models.SystemMembersAdded,
+		ActorID: actorID,
+		UserIDs: added,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// RemoveGroupMember исключает участника: admin — обычных участников, owner — любых.
+// Служебное сообщение пишется до исключения, чтобы его получил и исключённый.
+func RemoveGroupMember(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, userID int64) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := memberRole(ctx, tx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if models.RoleRank(role) < models.RoleRank(models.RoleAdmin) || models.RoleRank(role) <= models.RoleRank(target) {
+		return nil, ErrGroupForbidden
+	}
+
+	posted, err := postSystem(ctx, tx, conversationID, &models.SystemEvent{
+		Action:  models.SystemMemberRemoved,
+		ActorID: actorID,
+		UserIDs: []int64{userID},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove group member: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// LeaveGroup выводит пользователя из группы. Если уходит владелец, владельцем
+// становится самый давний админ (или участник, если админов нет); группа без
+// участников удаляется.
+func LeaveGroup(ctx context.Context, pool *pgxpool.Pool, conversationID, userID int64) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	posted, err := postSystem(ctx, tx, conversationID, &models.SystemEvent{
+		Action:  models.SystemMemberLeft,
+		ActorID: userID,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID); err != nil {
+		return nil, fmt.Errorf("failed to leave group: %w", err)
+	}
+
+	if role == models.RoleOwner {
+		var successor int64
+		err := tx.QueryRow(ctx, `
+			SELECT user_id FROM conversation_members
+			WHERE conversation_id = $1
+			ORDER BY `+roleRankSQL("role")+` DESC, joined_at, user_id
+			LIMIT 1
+		`, conversationID).Scan(&successor)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			if _, err := tx.Exec(ctx, `DELETE FROM conversations WHERE id = $1`, conversationID); err != nil {
+				return nil, fmt.Errorf("failed to delete empty group: %w", err)
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to find new group owner: %w", err)
+		default:
+			if _, err := tx.Exec(ctx, `
+				UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2
+			`, conversationID, successor, models.RoleOwner); err != nil {
+				return nil, fmt.Errorf("failed to transfer group ownership: %w", err)
+			}
+			posted, err = postSystem(ctx, tx, conversationID, &models.SystemEvent{
+				Action:  models.SystemOwnerChanged,
+				ActorID: userID,
+				UserIDs: []int64{successor},
+			}, posted)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// SetGroupMemberRole меняет роль участника (только владелец). Роль owner передаёт
+// владение: прежний владелец становится админом.
+func SetGroupMemberRole(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, userID int64, newRole string) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleOwner || userID == actorID {
+		return nil, ErrGroupForbidden
+	}
+	current, err := memberRole(ctx, tx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == newRole {
+		return nil, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID, newRole); err != nil {
+		return nil, fmt.Errorf("failed to set member role: %w", err)
+	}
+
+	event := &models.SystemEvent{
+		Action:  models.SystemRoleChanged,
+		ActorID: actorID,
+		UserIDs: []int64{userID},
+		Role:    newRole,
+	}
+	if newRole == models.RoleOwner {
+		if _, err := tx.Exec(ctx, `
+			UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2
+		`, conversationID, actorID, models.RoleAdmin); err != nil {
+			return nil, fmt.Errorf("failed to transfer group ownership: %w", err)
+		}
+		event = &models.SystemEvent{
+			Action:  models.SystemOwnerChanged,
+			ActorID: actorID,
+			UserIDs: []int64{userID},
+		}
+	}
+
+	posted, err := postSystem(ctx, tx, conversationID, event, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// UpdateGroup меняет название и/или аватар группы (нужна роль admin или owner);
+// nil — не менять. Отдаёт группу после изменения.
+func UpdateGroup(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID int64, title, avatar *string) (*models.Conversation, []PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if models.RoleRank(role) < models.RoleRank(models.RoleAdmin) {
+		return nil, nil, ErrGroupForbidden
+	}
+
+	c, err := scanConversation(tx.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = $1`, conversationID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	var posted []PostedMessage
+	if title != nil && (c.Title == nil || *c.Title != *title) {
+		if _, err := tx.Exec(ctx, `UPDATE conversations SET title = $2 WHERE id = $1`, conversationID, *title); err != nil {
+			return nil, nil, fmt.Errorf("failed to rename group: %w", err)
+		}
+		posted, err = postSystem(ctx, tx, conversationID, &models.SystemEvent{
+			Action:  models.SystemTitleChanged,
+			ActorID: actorID,
+			Title:   *title,
+		}, posted)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if avatar != nil && (c.Avatar == nil || *c.Avatar != *avatar) {
+		// Пустая строка убирает аватар
+		if _, err := tx.Exec(ctx, `UPDATE conversations SET avatar = NULLIF($2, '') WHERE id = $1`, conversationID, *avatar); err != nil {
+			return nil, nil, fmt.Errorf("failed to update group avatar: %w", err)
+		}
+		posted, err = postSystem(ctx, tx, conversationID, &models.SystemEvent{
+			Action:  models.SystemAvatarChanged,
+			ActorID: actorID,
+		}, posted)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(posted) > 0 {
+		c, err = scanConversation(tx.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = $1`, conversationID))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get group: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return c, posted, nil
+}
+
+// PinGroupMessage закрепляет сообщение группы (нужна роль admin или owner);
+// messageID = 0 открепляет. ErrNoSuchMessage — сообщения нет в этой группе.
+func PinGroupMessage(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, messageID int64) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if models.RoleRank(role) < models.RoleRank(models.RoleAdmin) {
+		return nil, ErrGroupForbidden
+	}
+
+	var pinned *int64
+	if err := tx.QueryRow(ctx, `SELECT pinned_message_id FROM conversations WHERE id = $1`, conversationID).Scan(&pinned); err != nil {
+		return nil, fmt.Errorf("failed to get pinned message: %w", err)
+	}
+
+	event := &models.SystemEvent{ActorID: actorID, MessageID: messageID}
+	if messageID == 0 {
+		if pinned == nil {
+			return nil, nil
+		}
+		event.Action = models.SystemMessageUnpinned
+		event.MessageID = *pinned
+	} else {
+		if pinned != nil && *pinned == messageID {
+			return nil, nil
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2 AND kind = $3)
+		`, messageID, conversationID, models.MessageText).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check message: %w", err)
+		}
+		if !exists {
+			return nil, ErrNoSuchMessage
+		}
+		event.Action = models.SystemMessagePinned
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversations SET pinned_message_id = NULLIF($2, 0) WHERE id = $1
+	`, conversationID, messageID); err != nil {
+		return nil, fmt.Errorf("failed to pin message: %w", err)
+	}
+
+	posted, err := postSystem(ctx, tx, conversationID, event, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// ListConversationMembers отдаёт участников переписки: сначала владелец и админы,
+// дальше по времени вступления
+func ListConversationMembers(ctx context.Context, pool *pgxpool.Pool, conversationID int64) ([]models.ConversationMember, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT u.id, u.name, u.picture, u.is_bot, cm.role, cm.joined_at
+		FROM conversation_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.conversation_id = $1
+		ORDER BY `+roleRankSQL("cm.role")+` DESC, cm.joined_at, u.id
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.ConversationMember{}
+	for rows.Next() {
+		var m models.ConversationMember
+		if err := rows.Scan(&m.ID, &m.Name, &m.Picture, &m.IsBot, &m.Role, &m.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation member: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list conversation members: %w", err)
+	}
+	return members, nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const testMaxMembers = 100
+
+// testGroup создаёт группу владельца owner с участниками members
+func testGroup(t *testing.T, pool *pgxpool.Pool, owner int64, members ...int64) int64 {
+	t.Helper()
+	c, _, err := CreateGroup(context.Background(), pool, owner, "group", nil, members, testMaxMembers)
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return c.ID
+}
+
+// testRoles отдаёт роли участников группы
+func testRoles(t *testing.T, pool *pgxpool.Pool, conversationID int64) map[int64]string {
+	t.Helper()
+	members, err := ListConversationMembers(context.Background(), pool, conversationID)
+	if err != nil {
+		t.Fatalf("list members: %v", err)
+	}
+	roles := make(map[int64]string, len(members))
+	for _, m := range members {
+		roles[m.ID] = m.Role
+	}
+	return roles
+}
+
+func testSetRole(t *testing.T, pool *pgxpool.Pool, conversationID, ownerID, userID int64, role string) {
+	t.Helper()
+	if _, err := SetGroupMemberRole(context.Background(), pool, conversationID, ownerID, userID, role); err != nil {
+		t.Fatalf("set role %s for %d: %v", role, userID, err)
+	}
+}
+
+func TestGroupMemberCannotManageMembers(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, member, other, outsider := testUser(t, pool, "owner"), testUser(t, pool, "member"), testUser(t, pool, "other"), testUser(t, pool, "outsider")
+	group := testGroup(t, pool, owner.ID, member.ID, other.ID)
+
+	if _, err := AddGroupMembers(ctx, pool, group, member.ID, []int64{outsider.ID}, testMaxMembers); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("member add: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := RemoveGroupMember(ctx, pool, group, member.ID, other.ID); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("member remove: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := SetGroupMemberRole(ctx, pool, group, member.ID, other.ID, models.RoleAdmin); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("member set role: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := AddGroupMembers(ctx, pool, group, outsider.ID, []int64{outsider.ID}, testMaxMembers); !errors.Is(err, ErrNotGroupMember) {
+		t.Fatalf("outsider add: expected ErrNotGroupMember, got %v", err)
+	}
+
+	if roles := testRoles(t, pool, group); len(roles) != 3 {
+		t.Fatalf("expected membership unchanged, got %v", roles)
+	}
+}
+
+func TestGroupAdminRankChecks(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, admin, admin2, member := testUser(t, pool, "owner"), testUser(t, pool, "admin"), testUser(t, pool, "admin2"), testUser(t, pool, "member")
+	group := testGroup(t, pool, owner.ID, admin.ID, admin2.ID, member.ID)
+	testSetRole(t, pool, group, owner.ID, admin.ID, models.RoleAdmin)
+	testSetRole(t, pool, group, owner.ID, admin2.ID, models.RoleAdmin)
+
+	if _, err := RemoveGroupMember(ctx, pool, group, admin.ID, admin2.ID); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("admin removes admin: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := RemoveGroupMember(ctx, pool, group, admin.ID, owner.ID); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("admin removes owner: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := SetGroupMemberRole(ctx, pool, group, admin.ID, member.ID, models.RoleAdmin); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("admin sets role: expected ErrGroupForbidden, got %v", err)
+	}
+
+	// Обычного участника админ исключить может, а владелец — и админа
+	if _, err := RemoveGroupMember(ctx, pool, group, admin.ID, member.ID); err != nil {
+		t.Fatalf("admin removes member: %v", err)
+	}
+	if _, err := RemoveGroupMember(ctx, pool, group, owner.ID, admin2.ID); err != nil {
+		t.Fatalf("owner removes admin: %v", err)
+	}
+
+	roles := testRoles(t, pool, group)
+	if len(roles) != 2 || roles[owner.ID] != models.RoleOwner || roles[admin.ID] != models.RoleAdmin {
+		t.Fatalf("unexpected members %v", roles)
+	}
+}
+
+func TestGroupOwnershipTransfer(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, member := testUser(t, pool, "owner"), testUser(t, pool, "member")
+	group := testGroup(t, pool, owner.ID, member.ID)
+
+	posted, err := SetGroupMemberRole(ctx, pool, group, owner.ID, member.ID, models.RoleOwner)
+	if err != nil {
+		t.Fatalf("SetGroupMemberRole: %v", err)
+	}
+	if len(posted) != 1 || posted[0].Message.System.Action != models.SystemOwnerChanged {
+		t.Fatalf("expected owner_changed message, got %+v", posted)
+	}
+
+	roles := testRoles(t, pool, group)
+	if roles[member.ID] != models.RoleOwner || roles[owner.ID] != models.RoleAdmin {
+		t.Fatalf("expected previous owner to become admin, got %v", roles)
+	}
+}
+
+// Уходящего владельца сменяет самый давний админ, даже если участник вступил раньше
+func TestLeaveGroupPromotesOldestAdmin(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, member, older, newer := testUser(t, pool, "owner"), testUser(t, pool, "member"), testUser(t, pool, "older"), testUser(t, pool, "newer")
+	group := testGroup(t, pool, owner.ID, member.ID)
+	for _, id := range []int64{older.ID, newer.ID} {
+		if _, err := AddGroupMembers(ctx, pool, group, owner.ID, []int64{id}, testMaxMembers); err != nil {
+			t.Fatalf("AddGroupMembers: %v", err)
+		}
+	}
+	testSetRole(t, pool, group, owner.ID, newer.ID, models.RoleAdmin)
+	testSetRole(t, pool, group, owner.ID, older.ID, models.RoleAdmin)
+
+	posted, err := LeaveGroup(ctx, pool, group, owner.ID)
+	if err != nil {
+		t.Fatalf("LeaveGroup: %v", err)
+	}
+	if len(posted) != 2 || posted[1].Message.System.Action != models.SystemOwnerChanged {
+		t.Fatalf("expected member_left and owner_changed messages, got %d", len(posted))
+	}
+
+	roles := testRoles(t, pool, group)
+	if _, ok := roles[owner.ID]; ok {
+		t.Fatal("expected owner to leave")
+	}
+	if roles[older.ID] != models.RoleOwner || roles[newer.ID] != models.RoleAdmin || roles[member.ID] != models.RoleMember {
+		t.Fatalf("unexpected roles after owner left: %v", roles)
+	}
+}
+
+func TestLeaveGroupDeletesEmptyGroup(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner := testUser(t, pool, "owner")
+	group := testGroup(t, pool, owner.ID)
+
+	if _, err := LeaveGroup(ctx, pool, group, owner.ID); err != nil {
+		t.Fatalf("LeaveGroup: %v", err)
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM conversations WHERE id = $1)`, group).Scan(&exists); err != nil {
+		t.Fatalf("check group: %v", err)
+	}
+	if exists {
+		t.Fatal("expected empty group to be deleted")
+	}
+}
+
+// Превышение лимита участников откатывает добавление целиком
+func TestAddGroupMembersLimit(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, a, b, c := testUser(t, pool, "owner"), testUser(t, pool, "a"), testUser(t, pool, "b"), testUser(t, pool, "c")
+	group := testGroup(t, pool, owner.ID, a.ID)
+
+	if _, err := AddGroupMembers(ctx, pool, group, owner.ID, []int64{b.ID, c.ID}, 3); !errors.Is(err, ErrGroupFull) {
+		t.Fatalf("expected ErrGroupFull, got %v", err)
+	}
+	if roles := testRoles(t, pool, group); len(roles) != 2 {
+		t.Fatalf("expected add to be rolled back, got %v", roles)
+	}
+
+	var messages int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM messages WHERE conversation_id = $1`, group).Scan(&messages); err != nil {
+		t.Fatalf("count messages: %v", err)
+	}
+	if messages != 2 { // group_created и members_added при создании
+		t.Fatalf("expected no members_added message after rollback, got %d messages", messages)
+	}
+
+	// Ровно до лимита добавлять можно
+	if _, err := AddGroupMembers(ctx, pool, group, owner.ID, []int64{b.ID}, 3); err != nil {
+		t.Fatalf("AddGroupMembers up to the limit: %v", err)
+	}
+
+	// Лимит действует и при создании группы
+	if _, _, err := CreateGroup(ctx, pool, owner.ID, "too big", nil, []int64{a.ID, b.ID, c.ID}, 3); !errors.Is(err, ErrGroupFull) {
+		t.Fatalf("CreateGroup: expected ErrGroupFull, got %v", err)
+	}
+}
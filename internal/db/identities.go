@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const identityColumns = `id, user_id, provider, subject, email, created_at`
+
+func scanIdentity(row pgx.Row) (*models.UserIdentity, error) {
+	var ident models.UserIdentity
+	err := row.Scan(
+		&ident.ID,
+		&ident.UserID,
+		&ident.Provider,
+		&ident.Subject,
+		&ident.Email,
+		&ident.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ident, nil
+}
+
+// GetOrCreateUserByIdentity находит пользователя по (provider, subject) или создаёт
+// нового пользователя вместе со способом входа. Профиль найденного пользователя
+// обновляется данными от провайдера.
+// Возвращает пользователя и флаг, указывающий, был ли он создан (true) или найден (false)
+func GetOrCreateUserByIdentity(ctx context.Context, pool *pgxpool.Pool, provider, subject, email, name, picture string) (*models.User, bool, error) {
+	user, created, err := getOrCreateUserByIdentity(ctx, pool, provider, subject, email, name, picture)
+	if errors.Is(err, errIdentityExists) {
+		// Параллельный первый вход уже создал пользователя — теперь поиск его найдёт
+		return getOrCreateUserByIdentity(ctx, pool, provider, subject, email, name, picture)
+	}
+	return user, created, err
+}
+
+func getOrCreateUserByIdentity(ctx context.Context, pool *pgxpool.Pool, provider, subject, email, name, picture string) (*models.User, bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ident, err := getIdentity(ctx, tx, provider, subject)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if ident != nil {
+		// Пользователь найден, обновляем информацию (на случай, если изменились email, name, picture)
+		user, err := updateUser(ctx, tx, ident.UserID, email, name, picture)
+		if err != nil {
+			return nil, false, err
+		}
+		if user == nil {
+			return nil, false, fmt.Errorf("user %d for identity not found", ident.UserID)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE user_identities SET email = $2 WHERE id = $1`, ident.ID, email); err != nil {
+			return nil, false, fmt.Errorf("failed to update identity: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, false, fmt.Errorf("failed to commit tx: %w", err)
+		}
+		return user, false, nil
+	}
+
+	// Пользователь не найден, создаём нового. Если провайдер не прислал имя
+	// (напр. вход по email), берём часть адреса до @
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	user, err := createUser(ctx, tx, email, name, picture)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := createIdentity(ctx, tx, user.ID, provider, subject, email); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return user, true, nil
+}
+
+// GetIdentity находит способ входа по провайдеру и subject
+func GetIdentity(ctx context.Context, pool *pgxpool.Pool, provider, subject string) (*models.UserIdentity, error) {
+	return getIdentity(ctx, pool, provider, subject)
+}
+
+func getIdentity(ctx context.Context, q querier, provider, subject string) (*models.UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	ident, err := scanIdentity(q.QueryRow(ctx, query, provider, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return ident, nil
+}
+
+// errIdentityExists — способ входа успел создать параллельный запрос
+var errIdentityExists = errors.New("identity already exists")
+
+func createIdentity(ctx context.Context, q querier, userID int64, provider, subject, email string) (*models.UserIdentity, error) {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING ` + identityColumns
+
+	ident, err := scanIdentity(q.QueryRow(ctx, query, userID, provider, subject, email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errIdentityExists
+		}
+		return nil, fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return ident, nil
+}
+
+// ErrLastIdentity — нельзя отвязать последний способ входа, иначе в аккаунт будет не войти
+var ErrLastIdentity = errors.New("cannot unlink the last identity")
+
+// ListUserIdentities возвращает способы входа пользователя
+func ListUserIdentities(ctx context.Context, pool *pgxpool.Pool, userID int64) ([]models.UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		ident, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, *ident)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// LinkIdentity привязывает способ входа к пользователю. Если он уже существует,
+// возвращает существующую запись и created=false — вызывающий проверяет, чья она.
+func LinkIdentity(ctx context.Context, pool *pgxpool.Pool, userID int64, provider, subject, email string) (*models.UserIdentity, bool, error) {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING ` + identityColumns
+
+	ident, err := scanIdentity(pool.QueryRow(ctx, query, userID, provider, subject, email))
+	if err == nil {
+		return ident, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	existing, err := getIdentity(ctx, pool, provider, subject)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, fmt.Errorf("identity %s/%s disappeared while linking", provider, subject)
+	}
+
+	return existing, false, nil
+}
+
+// UnlinkIdentity отвязывает способ входа от пользователя. Возвращает false, если
+// такого способа у пользователя нет, и ErrLastIdentity, если он последний.
+func UnlinkIdentity(ctx context.Context, pool *pgxpool.Pool, userID, identityID int64) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем пользователя, чтобы два параллельных unlink не удалили оба последних способа
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var total int
+	var owned bool
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(BOOL_OR(id = $2), FALSE)
+		FROM user_identities
+		WHERE user_id = $1
+	`, userID, identityID).Scan(&total, &owned)
+	if err != nil {
+		return false, fmt.Errorf("failed to count identities: %w", err)
+	}
+	if !owned {
+		return false, nil
+	}
+	if total <= 1 {
+		return false, ErrLastIdentity
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID); err != nil {
+		return false, fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// Одновременные первые входы одним способом дают одного пользователя без ошибок
+func TestGetOrCreateUserByIdentityConcurrent(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	const logins = 8
+	var wg sync.WaitGroup
+	ids := make([]int64, logins)
+	created := make([]bool, logins)
+	errs := make([]error, logins)
+	for i := range logins {
+		wg.Go(func() {
+			user, c, err := GetOrCreateUserByIdentity(ctx, pool, "google", "subject-1", "a@example.com", "Alice", "")
+			if err == nil {
+				ids[i], created[i] = user.ID, c
+			}
+			errs[i] = err
+		})
+	}
+	wg.Wait()
+
+	creators := 0
+	for i := range logins {
+		if errs[i] != nil {
+			t.Fatalf("login %d: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("logins got different users: %v", ids)
+		}
+		if created[i] {
+			creators++
+		}
+	}
+	if creators != 1 {
+		t.Fatalf("expected exactly one login to create the user, got %d", creators)
+	}
+
+	var users int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&users); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if users != 1 {
+		t.Fatalf("expected 1 user, got %d", users)
+	}
+}
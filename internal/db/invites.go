@@ -0,0 +1,384 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Ошибки вступления по ссылке
+var (
+	ErrInviteNotFound      = errors.New("invite not found") // ссылки нет, она отозвана, истекла или исчерпана
+	ErrInviteNeedsApproval = errors.New("invite requires admin approval")
+	ErrInviteNoApproval    = errors.New("invite does not require approval")
+	ErrInviteExhausted     = errors.New("invite usage limit reached") // лимит исчерпан, пока заявка ждала одобрения
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
+
+const groupInviteColumns = `id, conversation_id, token, created_by, requires_approval, max_uses, uses, created_at, expires_at, revoked_at`
+
+// activeInvite — условие действующей ссылки
+const activeInvite = `revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW()) AND (max_uses IS NULL OR uses < max_uses)`
+
+func scanGroupInvite(row pgx.Row) (*models.GroupInvite, error) {
+	var i models.GroupInvite
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.Token,
+		&i.CreatedBy,
+		&i.RequiresApproval,
+		&i.MaxUses,
+		&i.Uses,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// CreateGroupInvite создаёт ссылку-приглашение (нужна роль admin или owner).
+// maxUses и expiresAt — необязательные ограничения.
+func CreateGroupInvite(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID int64, token string, requiresApproval bool, maxUses *int, expiresAt *time.Time) (*models.GroupInvite, error) {
+	if err := requireGroupRole(ctx, pool, conversationID, actorID, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	invite, err := scanGroupInvite(pool.QueryRow(ctx, `
+		INSERT INTO group_invites (conversation_id, token, created_by, requires_approval, max_uses, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING `+groupInviteColumns,
+		conversationID, token, actorID, requiresApproval, maxUses, expiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group invite: %w", err)
+	}
+	return invite, nil
+}
+
+// ListGroupInvites отдаёт действующие ссылки группы, новые первыми (admin и owner)
+func ListGroupInvites(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID int64) ([]models.GroupInvite, error) {
+	if err := requireGroupRole(ctx, pool, conversationID, actorID, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT `+groupInviteColumns+` FROM group_invites
+		WHERE conversation_id = $1 AND `+activeInvite+`
+		ORDER BY created_at DESC, id DESC
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group invites: %w", err)
+	}
+	defer rows.Close()
+
+	invites := []models.GroupInvite{}
+	for rows.Next() {
+		i, err := scanGroupInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan group invite: %w", err)
+		}
+		invites = append(invites, *i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list group invites: %w", err)
+	}
+	return invites, nil
+}
+
+// RevokeGroupInvite отзывает ссылку группы (admin и owner); false — ссылки нет или она уже отозвана
+func RevokeGroupInvite(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, inviteID int64) (bool, error) {
+	if err := requireGroupRole(ctx, pool, conversationID, actorID, models.RoleAdmin); err != nil {
+		return false, err
+	}
+
+	tag, err := pool.Exec(ctx, `
+		UPDATE group_invites SET revoked_at = NOW()
+		WHERE id = $1 AND conversation_id = $2 AND revoked_at IS NULL
+	`, inviteID, conversationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke group invite: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetGroupPreview отдаёт группу по действующей ссылке без вступления (nil — ссылка не действует)
+func GetGroupPreview(ctx context.Context, pool *pgxpool.Pool, token string, userID int64) (*models.GroupPreview, error) {
+	var p models.GroupPreview
+	err := pool.QueryRow(ctx, `
+		SELECT c.id, c.title, c.avatar, i.requires_approval,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = c.id AND user_id = $2),
+		       EXISTS (SELECT 1 FROM group_join_requests WHERE conversation_id = c.id AND user_id = $2)
+		FROM group_invites i
+		JOIN conversations c ON c.id = i.conversation_id
+		WHERE i.token = $1 AND `+activeInvite+`
+	`, token, userID).Scan(&p.ConversationID, &p.Title, &p.Avatar, &p.RequiresApproval, &p.MemberCount, &p.IsMember, &p.Requested)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get group preview: %w", err)
+	}
+	return &p, nil
+}
+
+// lockInvite блокирует группу ссылки, а затем саму ссылку (в том же порядке,
+// что и остальные изменения группы) и отдаёт её, если она действует
+func lockInvite(ctx context.Context, tx pgx.Tx, token string) (*models.GroupInvite, error) {
+	var conversationID int64
+	err := tx.QueryRow(ctx, `SELECT conversation_id FROM group_invites WHERE token = $1`, token).Scan(&conversationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, fmt.Errorf("failed to get group invite: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM conversations WHERE id = $1 FOR UPDATE`, conversationID); err != nil {
+		return nil, fmt.Errorf("failed to lock group: %w", err)
+	}
+
+	invite, err := scanGroupInvite(tx.QueryRow(ctx, `
+		SELECT `+groupInviteColumns+` FROM group_invites
+		WHERE token = $1 AND `+activeInvite+`
+		FOR UPDATE
+	`, token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, fmt.Errorf("failed to lock group invite: %w", err)
+	}
+	return invite, nil
+}
+
+// JoinGroupByInvite добавляет пользователя в группу по ссылке без одобрения.
+// Если он уже в группе, ничего не меняет.
+func JoinGroupByInvite(ctx context.Context, pool *pgxpool.Pool, token string, userID int64, maxMembers int) (conversationID int64, posted []PostedMessage, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	invite, err := lockInvite(ctx, tx, token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := memberRole(ctx, tx, invite.ConversationID, userID); err == nil {
+		return invite.ConversationID, nil, nil
+	} else if !errors.Is(err, ErrNotGroupMember) {
+		return 0, nil, err
+	}
+	if invite.RequiresApproval {
+		return 0, nil, ErrInviteNeedsApproval
+	}
+
+	if _, err := addMembers(ctx, tx, invite.ConversationID, []int64{userID}, maxMembers); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE group_invites SET uses = uses + 1 WHERE id = $1`, invite.ID); err != nil {
+		return 0, nil, fmt.Errorf("failed to count invite use: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM group_join_requests WHERE conversation_id = $1 AND user_id = $2
+	`, invite.ConversationID, userID); err != nil {
+		return 0, nil, fmt.Errorf("failed to delete join request: %w", err)
+	}
+
+	posted, err = postSystem(ctx, tx, invite.ConversationID, &models.SystemEvent{
+		Action:  models.SystemMemberJoined,
+		ActorID: userID,
+	}, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return invite.ConversationID, posted, nil
+}
+
+// RequestToJoinGroup оставляет заявку на вступление по ссылке с одобрением.
+// member=true — пользователь уже в группе, заявка не нужна.
+func RequestToJoinGroup(ctx context.Context, pool *pgxpool.Pool, token string, userID int64) (conversationID int64, member bool, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	invite, err := lockInvite(ctx, tx, token)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err := memberRole(ctx, tx, invite.ConversationID, userID); err == nil {
+		return invite.ConversationID, true, nil
+	} else if !errors.Is(err, ErrNotGroupMember) {
+		return 0, false, err
+	}
+	if !invite.RequiresApproval {
+		return 0, false, ErrInviteNoApproval
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO group_join_requests (conversation_id, user_id, invite_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (conversation_id, user_id) DO NOTHING
+	`, invite.ConversationID, userID, invite.ID); err != nil {
+		return 0, false, fmt.Errorf("failed to create join request: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return invite.ConversationID, false, nil
+}
+
+// ListJoinRequests отдаёт заявки на вступление, старые первыми (admin и owner)
+func ListJoinRequests(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID int64) ([]models.JoinRequest, error) {
+	if err := requireGroupRole(ctx, pool, conversationID, actorID, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT u.id, u.name, u.picture, u.is_bot, r.invite_id, r.created_at
+		FROM group_join_requests r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.conversation_id = $1
+		ORDER BY r.created_at, u.id
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []models.JoinRequest{}
+	for rows.Next() {
+		var jr models.JoinRequest
+		if err := rows.Scan(&jr.ID, &jr.Name, &jr.Picture, &jr.IsBot, &jr.InviteID, &jr.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan join request: %w", err)
+		}
+		requests = append(requests, jr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ApproveJoinRequest принимает заявку (admin и owner): пользователь вступает в группу,
+// а использование засчитывается ссылке, по которой он пришёл
+func ApproveJoinRequest(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, userID int64, maxMembers int) ([]PostedMessage, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := lockGroup(ctx, tx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if models.RoleRank(role) < models.RoleRank(models.RoleAdmin) {
+		return nil, ErrGroupForbidden
+	}
+
+	var inviteID *int64
+	err = tx.QueryRow(ctx, `
+		DELETE FROM group_join_requests WHERE conversation_id = $1 AND user_id = $2
+		RETURNING invite_id
+	`, conversationID, userID).Scan(&inviteID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJoinRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to delete join request: %w", err)
+	}
+
+	added, err := addMembers(ctx, tx, conversationID, []int64{userID}, maxMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	var posted []PostedMessage
+	if len(added) > 0 {
+		if inviteID != nil {
+			// UPDATE блокирует строку ссылки, так что параллельные одобрения не превысят max_uses
+			tag, err := tx.Exec(ctx, `
+				UPDATE group_invites SET uses = uses + 1
+				WHERE id = $1 AND (max_uses IS NULL OR uses < max_uses)
+			`, *inviteID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count invite use: %w", err)
+			}
+			if tag.RowsAffected() == 0 {
+				return nil, ErrInviteExhausted
+			}
+		}
+		posted, err = postSystem(ctx, tx, conversationID, &models.SystemEvent{
+			Action:  models.SystemMembersAdded,
+			ActorID: actorID,
+			UserIDs: added,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return posted, nil
+}
+
+// DeclineJoinRequest отклоняет заявку (admin и owner); false — заявки нет
+func DeclineJoinRequest(ctx context.Context, pool *pgxpool.Pool, conversationID, actorID, userID int64) (bool, error) {
+	if err := requireGroupRole(ctx, pool, conversationID, actorID, models.RoleAdmin); err != nil {
+		return false, err
+	}
+
+	tag, err := pool.Exec(ctx, `
+		DELETE FROM group_join_requests WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to decline join request: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// mergeInvites переносит на основной аккаунт заявки на вступление и авторство ссылок
+// дубликата. Заявки в группы, где основной аккаунт уже состоит, не нужны.
+// Вызывается из MergeUsers после переноса переписок.
+func mergeInvites(ctx context.Context, tx pgx.Tx, primaryID, secondaryID int64) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO group_join_requests (conversation_id, user_id, invite_id, created_at)
+		SELECT r.conversation_id, $1, r.invite_id, r.created_at
+		FROM group_join_requests r
+		WHERE r.user_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM conversation_members m WHERE m.conversation_id = r.conversation_id AND m.user_id = $1
+		)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET
+			created_at = LEAST(group_join_requests.created_at, EXCLUDED.created_at)
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move join requests: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE group_invites SET created_by = $1 WHERE created_by = $2`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move group invites: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// testInvite создаёт ссылку группы от имени её владельца
+func testInvite(t *testing.T, pool *pgxpool.Pool, group, owner int64, token string, approval bool, maxUses *int, expiresAt *time.Time) *models.GroupInvite {
+	t.Helper()
+	invite, err := CreateGroupInvite(context.Background(), pool, group, owner, token, approval, maxUses, expiresAt)
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	return invite
+}
+
+func TestJoinGroupByInvite(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, alice := testUser(t, pool, "owner"), testUser(t, pool, "alice")
+	group := testGroup(t, pool, owner.ID)
+	testInvite(t, pool, group, owner.ID, "direct", false, nil, nil)
+
+	conversationID, posted, err := JoinGroupByInvite(ctx, pool, "direct", alice.ID, testMaxMembers)
+	if err != nil {
+		t.Fatalf("JoinGroupByInvite: %v", err)
+	}
+	if conversationID != group || len(posted) != 1 || posted[0].Message.System.Action != models.SystemMemberJoined {
+		t.Fatalf("unexpected join result %d, %+v", conversationID, posted)
+	}
+	if roles := testRoles(t, pool, group); roles[alice.ID] != models.RoleMember {
+		t.Fatalf("expected alice to join, got %v", roles)
+	}
+
+	// Повторное вступление ничего не меняет и не тратит использование
+	if _, posted, err := JoinGroupByInvite(ctx, pool, "direct", alice.ID, testMaxMembers); err != nil || posted != nil {
+		t.Fatalf("expected no-op for a member, got %+v, %v", posted, err)
+	}
+	var uses int
+	if err := pool.QueryRow(ctx, `SELECT uses FROM group_invites WHERE token = 'direct'`).Scan(&uses); err != nil {
+		t.Fatalf("get uses: %v", err)
+	}
+	if uses != 1 {
+		t.Fatalf("expected 1 use, got %d", uses)
+	}
+
+	if _, _, err := JoinGroupByInvite(ctx, pool, "missing", alice.ID, testMaxMembers); !errors.Is(err, ErrInviteNotFound) {
+		t.Fatalf("unknown token: expected ErrInviteNotFound, got %v", err)
+	}
+}
+
+func TestInviteExpiredRevokedExhausted(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, alice, bob := testUser(t, pool, "owner"), testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	group := testGroup(t, pool, owner.ID)
+
+	past := time.Now().Add(-time.Minute)
+	testInvite(t, pool, group, owner.ID, "expired", false, nil, &past)
+
+	revoked := testInvite(t, pool, group, owner.ID, "revoked", false, nil, nil)
+	if ok, err := RevokeGroupInvite(ctx, pool, group, owner.ID, revoked.ID); err != nil || !ok {
+		t.Fatalf("RevokeGroupInvite: %v, %v", ok, err)
+	}
+	if ok, err := RevokeGroupInvite(ctx, pool, group, owner.ID, revoked.ID); err != nil || ok {
+		t.Fatalf("second revoke: expected false, got %v, %v", ok, err)
+	}
+
+	one := 1
+	testInvite(t, pool, group, owner.ID, "single", false, &one, nil)
+	if _, _, err := JoinGroupByInvite(ctx, pool, "single", alice.ID, testMaxMembers); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	for _, token := range []string{"expired", "revoked", "single"} {
+		if _, _, err := JoinGroupByInvite(ctx, pool, token, bob.ID, testMaxMembers); !errors.Is(err, ErrInviteNotFound) {
+			t.Errorf("%s: expected ErrInviteNotFound on join, got %v", token, err)
+		}
+		if _, _, err := RequestToJoinGroup(ctx, pool, token, bob.ID); !errors.Is(err, ErrInviteNotFound) {
+			t.Errorf("%s: expected ErrInviteNotFound on request, got %v", token, err)
+		}
+		if p, err := GetGroupPreview(ctx, pool, token, bob.ID); err != nil || p != nil {
+			t.Errorf("%s: expected no preview, got %+v, %v", token, p, err)
+		}
+	}
+
+	invites, err := ListGroupInvites(ctx, pool, group, owner.ID)
+	if err != nil {
+		t.Fatalf("ListGroupInvites: %v", err)
+	}
+	if len(invites) != 0 {
+		t.Fatalf("expected no active invites, got %+v", invites)
+	}
+	if roles := testRoles(t, pool, group); len(roles) != 2 {
+		t.Fatalf("expected only owner and alice, got %v", roles)
+	}
+}
+
+// Ссылка с одобрением не пускает напрямую, а ссылка без одобрения не принимает заявки
+func TestInviteApprovalMode(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, alice := testUser(t, pool, "owner"), testUser(t, pool, "alice")
+	group := testGroup(t, pool, owner.ID)
+	testInvite(t, pool, group, owner.ID, "approval", true, nil, nil)
+	testInvite(t, pool, group, owner.ID, "direct", false, nil, nil)
+
+	if _, _, err := JoinGroupByInvite(ctx, pool, "approval", alice.ID, testMaxMembers); !errors.Is(err, ErrInviteNeedsApproval) {
+		t.Fatalf("expected ErrInviteNeedsApproval, got %v", err)
+	}
+	if _, _, err := RequestToJoinGroup(ctx, pool, "direct", alice.ID); !errors.Is(err, ErrInviteNoApproval) {
+		t.Fatalf("expected ErrInviteNoApproval, got %v", err)
+	}
+
+	// Участнику заявка не нужна
+	if _, member, err := RequestToJoinGroup(ctx, pool, "approval", owner.ID); err != nil || !member {
+		t.Fatalf("expected member=true for the owner, got %v, %v", member, err)
+	}
+
+	if _, member, err := RequestToJoinGroup(ctx, pool, "approval", alice.ID); err != nil || member {
+		t.Fatalf("RequestToJoinGroup: %v, %v", member, err)
+	}
+	preview, err := GetGroupPreview(ctx, pool, "approval", alice.ID)
+	if err != nil || preview == nil || !preview.Requested || preview.IsMember {
+		t.Fatalf("expected pending request in preview, got %+v, %v", preview, err)
+	}
+
+	posted, err := ApproveJoinRequest(ctx, pool, group, owner.ID, alice.ID, testMaxMembers)
+	if err != nil {
+		t.Fatalf("ApproveJoinRequest: %v", err)
+	}
+	if len(posted) != 1 || posted[0].Message.System.Action != models.SystemMembersAdded {
+		t.Fatalf("expected members_added message, got %+v", posted)
+	}
+	if roles := testRoles(t, pool, group); roles[alice.ID] != models.RoleMember {
+		t.Fatalf("expected alice to be approved, got %v", roles)
+	}
+
+	if _, err := ApproveJoinRequest(ctx, pool, group, owner.ID, alice.ID, testMaxMembers); !errors.Is(err, ErrJoinRequestNotFound) {
+		t.Fatalf("expected ErrJoinRequestNotFound, got %v", err)
+	}
+}
+
+func TestApproveJoinRequestPermissions(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, member, alice := testUser(t, pool, "owner"), testUser(t, pool, "member"), testUser(t, pool, "alice")
+	group := testGroup(t, pool, owner.ID, member.ID)
+	testInvite(t, pool, group, owner.ID, "approval", true, nil, nil)
+	if _, _, err := RequestToJoinGroup(ctx, pool, "approval", alice.ID); err != nil {
+		t.Fatalf("RequestToJoinGroup: %v", err)
+	}
+
+	if _, err := ApproveJoinRequest(ctx, pool, group, member.ID, alice.ID, testMaxMembers); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("member approve: expected ErrGroupForbidden, got %v", err)
+	}
+	if _, err := DeclineJoinRequest(ctx, pool, group, member.ID, alice.ID); !errors.Is(err, ErrGroupForbidden) {
+		t.Fatalf("member decline: expected ErrGroupForbidden, got %v", err)
+	}
+
+	if ok, err := DeclineJoinRequest(ctx, pool, group, owner.ID, alice.ID); err != nil || !ok {
+		t.Fatalf("DeclineJoinRequest: %v, %v", ok, err)
+	}
+	if _, err := ApproveJoinRequest(ctx, pool, group, owner.ID, alice.ID, testMaxMembers); !errors.Is(err, ErrJoinRequestNotFound) {
+		t.Fatalf("expected declined request to be gone, got %v", err)
+	}
+}
+
+// Заявки, оставленные до исчерпания лимита, нельзя одобрить сверх max_uses
+func TestApproveJoinRequestInviteExhausted(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	owner, alice, bob := testUser(t, pool, "owner"), testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	group := testGroup(t, pool, owner.ID)
+	one := 1
+	testInvite(t, pool, group, owner.ID, "approval", true, &one, nil)
+
+	for _, u := range []int64{alice.ID, bob.ID} {
+		if _, _, err := RequestToJoinGroup(ctx, pool, "approval", u); err != nil {
+			t.Fatalf("RequestToJoinGroup: %v", err)
+		}
+	}
+
+	if _, err := ApproveJoinRequest(ctx, pool, group, owner.ID, alice.ID, testMaxMembers); err != nil {
+		t.Fatalf("first approval: %v", err)
+	}
+	if _, err := ApproveJoinRequest(ctx, pool, group, owner.ID, bob.ID, testMaxMembers); !errors.Is(err, ErrInviteExhausted) {
+		t.Fatalf("expected ErrInviteExhausted, got %v", err)
+	}
+
+	// Неудачное одобрение откатывается целиком: bob не в группе, заявка осталась
+	if roles := testRoles(t, pool, group); len(roles) != 2 || roles[bob.ID] != "" {
+		t.Fatalf("expected bob not to join, got %v", roles)
+	}
+	requests, err := ListJoinRequests(ctx, pool, group, owner.ID)
+	if err != nil {
+		t.Fatalf("ListJoinRequests: %v", err)
+	}
+	if len(requests) != 1 || requests[0].ID != bob.ID {
+		t.Fatalf("expected bob's request to remain, got %+v", requests)
+	}
+}
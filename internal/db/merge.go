@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMergeSameUser — попытка слить аккаунт сам с собой
+var ErrMergeSameUser = errors.New("cannot merge user into itself")
+
+// MergeUsers в одной транзакции переносит всё, что принадлежит дублирующему аккаунту
+// (secondaryID), в основной (primaryID), и удаляет дубликат.
+// При добавлении новых таблиц с данными пользователя их нужно переносить здесь же.
+// Возвращает ID сессий дубликата — они удалены, их сокеты нужно закрыть.
+func MergeUsers(ctx context.Context, pool *pgxpool.Pool, primaryID, secondaryID int64) ([]int64, error) {
+	if primaryID == secondaryID {
+		return nil, ErrMergeSameUser
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем оба аккаунта в порядке id, чтобы встречные слияния не ловили дедлок
+	rows, err := tx.Query(ctx, `SELECT id FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, primaryID, secondaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+	locked, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+	if len(locked) != 2 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// Способы входа
+	if _, err := tx.Exec(ctx, `UPDATE user_identities SET user_id = $1 WHERE user_id = $2`, primaryID, secondaryID); err != nil {
+		return nil, fmt.Errorf("failed to move identities: %w", err)
+	}
+
+	// Боты дубликата переходят к основному аккаунту вместе со своими токенами
+	if _, err := tx.Exec(ctx, `UPDATE users SET bot_owner_id = $1 WHERE bot_owner_id = $2`, primaryID, secondaryID); err != nil {
+		return nil, fmt.Errorf("failed to move bots: %w", err)
+	}
+
+	// Присутствие: последний визит — более поздний, скрытие last seen — если скрывал любой из аккаунтов
+	if _, err := tx.Exec(ctx, `
+		UPDATE users p
+		SET last_seen_at = GREATEST(p.last_seen_at, s.last_seen_at),
+		    hide_last_seen = p.hide_last_seen OR s.hide_last_seen
+		FROM users s
+		WHERE p.id = $1 AND s.id = $2
+	`, primaryID, secondaryID); err != nil {
+		return nil, fmt.Errorf("failed to merge presence settings: %w", err)
+	}
+
+	// Переписки и сообщения
+	if err := mergeConversations(ctx, tx, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+	if err := mergeInbox(ctx, tx, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+	if err := mergeReceipts(ctx, tx, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO message_hidden (message_id, user_id, hidden_at)
+		SELECT message_id, $1, hidden_at FROM message_hidden WHERE user_id = $2
+		ON CONFLICT (user_id, message_id) DO NOTHING
+	`, primaryID, secondaryID); err != nil {
+		return nil, fmt.Errorf("failed to move hidden messages: %w", err)
+	}
+	if err := mergeReactions(ctx, tx, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+	if err := mergeInvites(ctx, tx, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+
+	// Сессии дубликата не переносим: его устройства должны перелогиниться
+	rows, err = tx.Query(ctx, `SELECT id FROM sessions WHERE user_id = $1`, secondaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sessionIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	// Удаление каскадно чистит сессии, refresh- и персональные токены дубликата
+	if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, secondaryID); err != nil {
+		return nil, fmt.Errorf("failed to delete merged user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return sessionIDs, nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+// После слияния перенесённая история попадает в ленту основного аккаунта с новыми seq
+func TestMergeUsersMovesInbox(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob, carol := testUser(t, pool, "alice"), testUser(t, pool, "bob"), testUser(t, pool, "carol")
+
+	toAlice := testDirectMessage(t, pool, carol.ID, alice.ID, "to alice")
+	toBob1 := testDirectMessage(t, pool, carol.ID, bob.ID, "to bob 1")
+	toBob2 := testDirectMessage(t, pool, carol.ID, bob.ID, "to bob 2")
+
+	if _, err := MergeUsers(ctx, pool, alice.ID, bob.ID); err != nil {
+		t.Fatalf("MergeUsers: %v", err)
+	}
+
+	lastSeq, err := GetLastSeq(ctx, pool, alice.ID)
+	if err != nil {
+		t.Fatalf("GetLastSeq: %v", err)
+	}
+	if lastSeq != 3 {
+		t.Fatalf("expected last_seq 3, got %d", lastSeq)
+	}
+
+	// Устройство основного аккаунта подтвердило seq 1 — досылка отдаёт историю дубликата
+	msgs, hasMore, err := ListInbox(ctx, pool, alice.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("ListInbox: %v", err)
+	}
+	if hasMore || len(msgs) != 2 {
+		t.Fatalf("expected 2 replayed messages, got %d (hasMore=%v)", len(msgs), hasMore)
+	}
+	if msgs[0].ID != toBob1.ID || msgs[0].Seq != 2 || msgs[1].ID != toBob2.ID || msgs[1].Seq != 3 {
+		t.Fatalf("unexpected replay %+v", msgs)
+	}
+
+	all, _, err := ListInbox(ctx, pool, alice.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("ListInbox: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != toAlice.ID {
+		t.Fatalf("unexpected inbox %+v", all)
+	}
+
+	// Новое сообщение продолжает нумерацию
+	_, seqs, err := SendDirectMessage(ctx, pool, carol.ID, alice.ID, "after merge")
+	if err != nil {
+		t.Fatalf("SendDirectMessage: %v", err)
+	}
+	if seqs[alice.ID] != 4 {
+		t.Fatalf("expected seq 4 after merge, got %d", seqs[alice.ID])
+	}
+}
+
+// Заявка дубликата на вступление переходит к основному аккаунту
+func TestMergeUsersMovesJoinRequests(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob, owner := testUser(t, pool, "alice"), testUser(t, pool, "bob"), testUser(t, pool, "owner")
+
+	group, _, err := CreateGroup(ctx, pool, owner.ID, "group", nil, nil, 10)
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	invite, err := CreateGroupInvite(ctx, pool, group.ID, owner.ID, "merge-test-token", true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateGroupInvite: %v", err)
+	}
+	if _, _, err := RequestToJoinGroup(ctx, pool, invite.Token, bob.ID); err != nil {
+		t.Fatalf("RequestToJoinGroup: %v", err)
+	}
+
+	if _, err := MergeUsers(ctx, pool, alice.ID, bob.ID); err != nil {
+		t.Fatalf("MergeUsers: %v", err)
+	}
+
+	requests, err := ListJoinRequests(ctx, pool, group.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("ListJoinRequests: %v", err)
+	}
+	if len(requests) != 1 || requests[0].ID != alice.ID {
+		t.Fatalf("expected join request of primary account, got %+v", requests)
+	}
+	if requests[0].InviteID == nil || *requests[0].InviteID != invite.ID {
+		t.Fatalf("expected invite to be kept, got %v", requests[0].InviteID)
+	}
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Ошибки правки и удаления сообщений
+var (
+	ErrNotMessageAuthor   = errors.New("not the message author")
+	ErrMessageEditExpired = errors.New("message edit window expired")
+	ErrMessageDeleted     = errors.New("message is deleted")
+)
+
+// getMemberMessage отдаёт сообщение, если userID участник его переписки (иначе nil).
+// lock — заблокировать сообщение до конца транзакции.
+func getMemberMessage(ctx context.Context, q querier, messageID, userID int64, lock bool) (*models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + ` FROM messages
+		WHERE id = $1 AND EXISTS (
+			SELECT 1 FROM conversation_members cm
+			WHERE cm.conversation_id = messages.conversation_id AND cm.user_id = $2
+		)`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	m, err := scanMessage(q.QueryRow(ctx, query, messageID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	return m, nil
+}
+
+// lockOwnMessage блокирует сообщение автора, которое ещё можно менять: не удалено
+// и отправлено не раньше window назад
+func lockOwnMessage(ctx context.Context, tx pgx.Tx, messageID, userID int64, window time.Duration) (*models.Message, error) {
+	m, err := getMemberMessage(ctx, tx, messageID, userID, true)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	if m.SenderID != userID || m.Kind != models.MessageText {
+		return nil, ErrNotMessageAuthor
+	}
+	if m.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if time.Since(m.CreatedAt) > window {
+		return nil, ErrMessageEditExpired
+	}
+	return m, nil
+}
+
+// EditMessage меняет текст сообщения автора в пределах window после отправки
+// и сохраняет прежний текст в историю правок. nil — сообщения нет или
+// пользователь не участник переписки.
+func EditMessage(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64, content string, window time.Duration) (*models.Message, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	m, err := lockOwnMessage(ctx, tx, messageID, userID, window)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	if m.Content == content {
+		return m, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO message_edits (message_id, content, edited_at) VALUES ($1, $2, NOW())
+	`, messageID, m.Content); err != nil {
+		return nil, fmt.Errorf("failed to save message edit: %w", err)
+	}
+
+	m, err = scanMessage(tx.QueryRow(ctx, `
+		UPDATE messages SET content = $2, edited_at = NOW()
+		WHERE id = $1
+		RETURNING `+messageColumns,
+		messageID, content,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return m, nil
+}
+
+// DeleteMessageForEveryone удаляет сообщение автора у всех в пределах window после
+// отправки: текст, история правок и реакции стираются, остаётся отметка об удалении
+func DeleteMessageForEveryone(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64, window time.Duration) (*models.Message, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	m, err := lockOwnMessage(ctx, tx, messageID, userID, window)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	m, err = scanMessage(tx.QueryRow(ctx, `
+		UPDATE messages SET content = '', system = NULL, deleted_at = NOW()
+		WHERE id = $1
+		RETURNING `+messageColumns,
+		messageID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM message_edits WHERE message_id = $1`, messageID); err != nil {
+		return nil, fmt.Errorf("failed to delete message edits: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM message_reactions WHERE message_id = $1`, messageID); err != nil {
+		return nil, fmt.Errorf("failed to delete message reactions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversations SET pinned_message_id = NULL WHERE pinned_message_id = $1
+	`, messageID); err != nil {
+		return nil, fmt.Errorf("failed to unpin deleted message: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return m, nil
+}
+
+// DeleteMessageForMe скрывает любое сообщение переписки только у пользователя.
+// Отдаёт сообщение уже отметкой об удалении; nil — сообщения нет или
+// пользователь не участник переписки.
+func DeleteMessageForMe(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64) (*models.Message, error) {
+	m, err := getMemberMessage(ctx, pool, messageID, userID, false)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	var hiddenAt time.Time
+	err = pool.QueryRow(ctx, `
+		INSERT INTO message_hidden (message_id, user_id, hidden_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id, message_id) DO UPDATE SET hidden_at = message_hidden.hidden_at
+		RETURNING hidden_at
+	`, messageID, userID).Scan(&hiddenAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hide message: %w", err)
+	}
+
+	if m.DeletedAt == nil {
+		m.Tombstone(models.DeletedForMe, hiddenAt)
+	}
+	return m, nil
+}
+
+// ListMessageEdits отдаёт прежние версии сообщения от старых к новым.
+// ok=false — сообщения нет или пользователь не участник переписки.
+func ListMessageEdits(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64) (edits []models.MessageEdit, ok bool, err error) {
+	m, err := getMemberMessage(ctx, pool, messageID, userID, false)
+	if err != nil || m == nil {
+		return nil, false, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT content, edited_at FROM message_edits
+		WHERE message_id = $1
+		ORDER BY edited_at, id
+	`, messageID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list message edits: %w", err)
+	}
+	defer rows.Close()
+
+	edits = []models.MessageEdit{}
+	for rows.Next() {
+		var e models.MessageEdit
+		if err := rows.Scan(&e.Content, &e.EditedAt); err != nil {
+			return nil, false, fmt.Errorf("failed to scan message edit: %w", err)
+		}
+		edits = append(edits, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to list message edits: %w", err)
+	}
+	return edits, true, nil
+}
+
+// applyHidden превращает в отметки об удалении сообщения, которые userID удалил у себя
+func applyHidden(ctx context.Context, q querier, userID int64, msgs []models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(msgs))
+	index := make(map[int64]int, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+		index[m.ID] = i
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT message_id, hidden_at FROM message_hidden
+		WHERE user_id = $1 AND message_id = ANY($2)
+	`, userID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to list hidden messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var hiddenAt time.Time
+		if err := rows.Scan(&messageID, &hiddenAt); err != nil {
+			return fmt.Errorf("failed to scan hidden message: %w", err)
+		}
+		if m := &msgs[index[messageID]]; m.DeletedAt == nil {
+			m.Tombstone(models.DeletedForMe, hiddenAt)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list hidden messages: %w", err)
+	}
+	return nil
+}
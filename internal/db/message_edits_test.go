@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+func TestEditMessage(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob, outsider := testUser(t, pool, "alice"), testUser(t, pool, "bob"), testUser(t, pool, "outsider")
+	msg := testDirectMessage(t, pool, alice.ID, bob.ID, "v1")
+
+	if _, err := EditMessage(ctx, pool, msg.ID, bob.ID, "hacked", time.Hour); !errors.Is(err, ErrNotMessageAuthor) {
+		t.Fatalf("recipient edit: expected ErrNotMessageAuthor, got %v", err)
+	}
+	if m, err := EditMessage(ctx, pool, msg.ID, outsider.ID, "hacked", time.Hour); err != nil || m != nil {
+		t.Fatalf("outsider edit: expected nil message, got %v, %v", m, err)
+	}
+
+	for _, content := range []string{"v2", "v2", "v3"} {
+		m, err := EditMessage(ctx, pool, msg.ID, alice.ID, content, time.Hour)
+		if err != nil {
+			t.Fatalf("EditMessage %s: %v", content, err)
+		}
+		if m.Content != content || m.EditedAt == nil {
+			t.Fatalf("unexpected edited message %+v", m)
+		}
+	}
+
+	// Правка тем же текстом историю не пополняет
+	edits, ok, err := ListMessageEdits(ctx, pool, msg.ID, bob.ID)
+	if err != nil || !ok {
+		t.Fatalf("ListMessageEdits: %v, %v", ok, err)
+	}
+	if len(edits) != 2 || edits[0].Content != "v1" || edits[1].Content != "v2" {
+		t.Fatalf("unexpected edit history %+v", edits)
+	}
+	if _, ok, err := ListMessageEdits(ctx, pool, msg.ID, outsider.ID); err != nil || ok {
+		t.Fatalf("outsider history: expected ok=false, got %v, %v", ok, err)
+	}
+}
+
+func TestEditWindow(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	msg := testDirectMessage(t, pool, alice.ID, bob.ID, "old")
+	if _, err := pool.Exec(ctx, `UPDATE messages SET created_at = NOW() - INTERVAL '2 hours' WHERE id = $1`, msg.ID); err != nil {
+		t.Fatalf("backdate message: %v", err)
+	}
+
+	if _, err := EditMessage(ctx, pool, msg.ID, alice.ID, "new", time.Hour); !errors.Is(err, ErrMessageEditExpired) {
+		t.Fatalf("edit: expected ErrMessageEditExpired, got %v", err)
+	}
+	if _, err := DeleteMessageForEveryone(ctx, pool, msg.ID, alice.ID, time.Hour); !errors.Is(err, ErrMessageEditExpired) {
+		t.Fatalf("delete: expected ErrMessageEditExpired, got %v", err)
+	}
+	if _, err := EditMessage(ctx, pool, msg.ID, alice.ID, "new", 3*time.Hour); err != nil {
+		t.Fatalf("edit within a wider window: %v", err)
+	}
+}
+
+// Удаление у всех оставляет отметку и стирает правки, реакции и закрепление
+func TestDeleteMessageForEveryone(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	group := testGroup(t, pool, alice.ID, bob.ID)
+
+	msg, _, ok, err := SendMessage(ctx, pool, group, alice.ID, "v1")
+	if err != nil || !ok {
+		t.Fatalf("SendMessage: %v, %v", ok, err)
+	}
+	if _, err := EditMessage(ctx, pool, msg.ID, alice.ID, "v2", time.Hour); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if _, _, err := AddReaction(ctx, pool, msg.ID, bob.ID, "👍"); err != nil {
+		t.Fatalf("AddReaction: %v", err)
+	}
+	if _, err := PinGroupMessage(ctx, pool, group, alice.ID, msg.ID); err != nil {
+		t.Fatalf("PinGroupMessage: %v", err)
+	}
+
+	if _, err := DeleteMessageForEveryone(ctx, pool, msg.ID, bob.ID, time.Hour); !errors.Is(err, ErrNotMessageAuthor) {
+		t.Fatalf("recipient delete: expected ErrNotMessageAuthor, got %v", err)
+	}
+
+	deleted, err := DeleteMessageForEveryone(ctx, pool, msg.ID, alice.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("DeleteMessageForEveryone: %v", err)
+	}
+	if deleted.DeletedAt == nil || deleted.DeletedFor != models.DeletedForEveryone || deleted.Content != "" {
+		t.Fatalf("expected tombstone, got %+v", deleted)
+	}
+
+	var edits, reactions int
+	var pinned *int64
+	if err := pool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM message_edits WHERE message_id = $1),
+		       (SELECT COUNT(*) FROM message_reactions WHERE message_id = $1),
+		       (SELECT pinned_message_id FROM conversations WHERE id = $2)
+	`, msg.ID, group).Scan(&edits, &reactions, &pinned); err != nil {
+		t.Fatalf("check cleanup: %v", err)
+	}
+	if edits != 0 || reactions != 0 || pinned != nil {
+		t.Fatalf("expected cleanup, got %d edits, %d reactions, pinned %v", edits, reactions, pinned)
+	}
+
+	if _, err := EditMessage(ctx, pool, msg.ID, alice.ID, "v3", time.Hour); !errors.Is(err, ErrMessageDeleted) {
+		t.Fatalf("edit after delete: expected ErrMessageDeleted, got %v", err)
+	}
+}
+
+// Удалённое у себя видно в истории только этому пользователю как отметка
+func TestDeleteMessageForMe(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	msg := testDirectMessage(t, pool, alice.ID, bob.ID, "secret")
+
+	// Скрыть можно и чужое сообщение; повтор ничего не меняет
+	first, err := DeleteMessageForMe(ctx, pool, msg.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("DeleteMessageForMe: %v", err)
+	}
+	again, err := DeleteMessageForMe(ctx, pool, msg.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("DeleteMessageForMe again: %v", err)
+	}
+	if first.DeletedFor != models.DeletedForMe || !again.DeletedAt.Equal(*first.DeletedAt) {
+		t.Fatalf("expected stable tombstone, got %+v and %+v", first, again)
+	}
+
+	bobView, _, err := ListMessages(ctx, pool, msg.ConversationID, bob.ID, nil, nil, 10)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if m := bobView[0]; m.DeletedFor != models.DeletedForMe || m.Content != "" {
+		t.Fatalf("expected tombstone for bob, got %+v", m)
+	}
+
+	aliceView, _, err := ListMessages(ctx, pool, msg.ConversationID, alice.ID, nil, nil, 10)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if m := aliceView[0]; m.DeletedAt != nil || m.Content != "secret" {
+		t.Fatalf("expected message intact for alice, got %+v", m)
+	}
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const messageColumns = `id, conversation_id, sender_id, kind, content, system, created_at, edited_at, deleted_at`
+
+// scanMessage читает messageColumns и, если запрошены, дополнительные колонки в extra
+func scanMessage(row pgx.Row, extra ...any) (*models.Message, error) {
+	var m models.Message
+	dest := []any{
+		&m.ID,
+		&m.ConversationID,
+		&m.SenderID,
+		&m.Kind,
+		&m.Content,
+		&m.System,
+		&m.CreatedAt,
+		&m.EditedAt,
+		&m.DeletedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if m.DeletedAt != nil {
+		m.DeletedFor = models.DeletedForEveryone
+	}
+	return &m, nil
+}
+
+// SendDirectMessage сохраняет сообщение в личную переписку отправителя и получателя,
+// создавая переписку при первом сообщении. seqs — номер сообщения в ленте каждого участника.
+func SendDirectMessage(ctx context.Context, pool *pgxpool.Pool, senderID, recipientID int64, content string) (msg *models.Message, seqs map[int64]int64, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	conv, err := getOrCreateDirectConversation(ctx, tx, senderID, recipientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg, seqs, err = createMessage(ctx, tx, conv.ID, senderID, content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return msg, seqs, nil
+}
+
+// SendMessage сохраняет сообщение в переписку, где отправитель участник.
+// ok=false — переписки нет или отправитель в ней не состоит.
+func SendMessage(ctx context.Context, pool *pgxpool.Pool, conversationID, senderID int64, content string) (msg *models.Message, seqs map[int64]int64, ok bool, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем участие, чтобы отправитель не вышел из группы посреди отправки
+	var member bool
+	err = tx.QueryRow(ctx, `
+		SELECT true FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+		FOR SHARE
+	`, conversationID, senderID).Scan(&member)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, fmt.Errorf("failed to check conversation member: %w", err)
+	}
+
+	msg, seqs, err = createMessage(ctx, tx, conversationID, senderID, content)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return msg, seqs, true, nil
+}
+
+// createMessage добавляет сообщение, сдвигает последнюю активность переписки
+// и кладёт сообщение в ленты участников
+func createMessage(ctx context.Context, q querier, conversationID, senderID int64, content string) (*models.Message, map[int64]int64, error) {
+	return insertMessage(ctx, q, conversationID, senderID, models.MessageText, content, nil)
+}
+
+// createSystemMessage пишет в переписку служебное сообщение о событии группы
+func createSystemMessage(ctx context.Context, q querier, conversationID int64, event *models.SystemEvent) (*models.Message, map[int64]int64, error) {
+	names, err := userNames(ctx, q, append([]int64{event.ActorID}, event.UserIDs...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return insertMessage(ctx, q, conversationID, event.ActorID, models.MessageSystem, event.Text(names), event)
+}
+
+func insertMessage(ctx context.Context, q querier, conversationID, senderID int64, kind, content string, system *models.SystemEvent) (*models.Message, map[int64]int64, error) {
+	query := `
+		INSERT INTO messages (conversation_id, sender_id, kind, content, system, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING ` + messageColumns
+
+	msg, err := scanMessage(q.QueryRow(ctx, query, conversationID, senderID, kind, content, system))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	if _, err := q.Exec(ctx, `
+		UPDATE conversations SET last_message_id = $2, last_message_at = $3
+		WHERE id = $1
+	`, conversationID, msg.ID, msg.CreatedAt); err != nil {
+		return nil, nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	seqs, err := assignSeqs(ctx, q, conversationID, msg.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msg, seqs, nil
+}
+
+// ListMessages отдаёт страницу истории переписки в хронологическом порядке.
+// С after — limit сообщений сразу после курсора; иначе — limit сообщений перед
+// before (или самые новые, если before не задан). hasMore — есть ли ещё сообщения
+// в направлении запроса. У сообщений заполнены отметки получателей и счётчики
+// реакций; удалённые пользователем userID у себя отдаются отметками об удалении.
+func ListMessages(ctx context.Context, pool *pgxpool.Pool, conversationID, userID int64, before, after *Cursor, limit int) (msgs []models.Message, hasMore bool, err error) {
+	args := []any{conversationID, limit + 1}
+	var conds []string
+	if before != nil {
+		args = append(args, before.Time, before.ID)
+		conds = append(conds, fmt.Sprintf(`AND (created_at, id) < ($%d, $%d)`, len(args)-1, len(args)))
+	}
+	if after != nil {
+		args = append(args, after.Time, after.ID)
+		conds = append(conds, fmt.Sprintf(`AND (created_at, id) > ($%d, $%d)`, len(args)-1, len(args)))
+	}
+
+	// Листаем от курсора: вперёд по возрастанию, назад — по убыванию
+	order := `DESC`
+	if after != nil {
+		order = `ASC`
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT `+messageColumns+` FROM messages
+		WHERE conversation_id = $1 `+strings.Join(conds, " ")+`
+		ORDER BY created_at `+order+`, id `+order+`
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer rows.Close()
+
+	msgs = []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan message: %w", err)
+		}
+		msgs = append(msgs, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		hasMore = true
+	}
+	if after == nil {
+		slices.Reverse(msgs)
+	}
+
+	if err := applyHidden(ctx, pool, userID, msgs); err != nil {
+		return nil, false, err
+	}
+	if err := attachReceipts(ctx, pool, userID, msgs); err != nil {
+		return nil, false, err
+	}
+	if err := attachReactions(ctx, pool, userID, msgs); err != nil {
+		return nil, false, err
+	}
+
+	return msgs, hasMore, nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Сообщения с одинаковым created_at (до микросекунды) листаются по id без пропусков и повторов
+func TestListMessagesSameMicrosecond(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+
+	var want []int64
+	var conversationID int64
+	for i := range 7 {
+		msg := testDirectMessage(t, pool, alice.ID, bob.ID, string(rune('a'+i)))
+		want = append(want, msg.ID)
+		conversationID = msg.ConversationID
+	}
+
+	at := time.Date(2026, 10, 17, 12, 0, 0, 123456000, time.UTC)
+	if _, err := pool.Exec(ctx, `UPDATE messages SET created_at = $2 WHERE conversation_id = $1`, conversationID, at); err != nil {
+		t.Fatalf("set created_at: %v", err)
+	}
+
+	// Назад от самых новых страницами по 2
+	var backward []int64
+	var before *Cursor
+	for {
+		page, hasMore, err := ListMessages(ctx, pool, conversationID, bob.ID, before, nil, 2)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		ids := make([]int64, len(page))
+		for i, m := range page {
+			ids[i] = m.ID
+		}
+		backward = append(ids, backward...)
+		if !hasMore {
+			break
+		}
+		before = &Cursor{Time: page[0].CreatedAt, ID: page[0].ID}
+	}
+	if !slices.Equal(backward, want) {
+		t.Fatalf("backward pagination: got %v, want %v", backward, want)
+	}
+
+	// Вперёд от самого старого
+	forward := []int64{want[0]}
+	after := &Cursor{Time: at, ID: want[0]}
+	for {
+		page, hasMore, err := ListMessages(ctx, pool, conversationID, bob.ID, nil, after, 3)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		for _, m := range page {
+			forward = append(forward, m.ID)
+		}
+		if !hasMore {
+			break
+		}
+		last := page[len(page)-1]
+		after = &Cursor{Time: last.CreatedAt, ID: last.ID}
+	}
+	if !slices.Equal(forward, want) {
+		t.Fatalf("forward pagination: got %v, want %v", forward, want)
+	}
+}
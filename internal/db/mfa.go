@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// ErrMFAAlreadyEnabled — второй фактор уже подключён, перед новым подключением его нужно отключить
+var ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+
+// GetUserMFA возвращает настройки второго фактора (nil — не подключался)
+func GetUserMFA(ctx context.Context, pool *pgxpool.Pool, userID int64) (*models.UserMFA, error) {
+	var m models.UserMFA
+	err := pool.QueryRow(ctx, `
+		SELECT user_id, secret, last_used_step, created_at, confirmed_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`, userID).Scan(&m.UserID, &m.Secret, &m.LastUsedStep, &m.CreatedAt, &m.ConfirmedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user mfa: %w", err)
+	}
+
+	return &m, nil
+}
+
+// StartMFAEnrollment сохраняет новый неподтверждённый секрет (повторный вызов заменяет его)
+func StartMFAEnrollment(ctx context.Context, pool *pgxpool.Pool, userID int64, secret string) error {
+	tag, err := pool.Exec(ctx, `
+		INSERT INTO user_mfa (user_id, secret, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.confirmed_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to start mfa enrollment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmMFA включает второй фактор и заменяет коды восстановления новыми
+func ConfirmMFA(ctx context.Context, pool *pgxpool.Pool, userID, step int64, recoveryCodeHashes []string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_mfa
+		SET confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to confirm mfa: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// DisableMFA отключает второй фактор и удаляет коды восстановления
+func DisableMFA(ctx context.Context, pool *pgxpool.Pool, userID int64) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep отмечает шаг TOTP использованным. false — этот или более поздний код
+// уже принимался (защита от повторного использования перехваченного кода).
+func UseTOTPStep(ctx context.Context, pool *pgxpool.Pool, userID, step int64) (bool, error) {
+	tag, err := pool.Exec(ctx, `
+		UPDATE user_mfa SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode погашает код восстановления. false — кода нет или он уже использован.
+func UseRecoveryCode(ctx context.Context, pool *pgxpool.Pool, userID int64, codeHash string) (bool, error) {
+	tag, err := pool.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления
+func CountRecoveryCodes(ctx context.Context, pool *pgxpool.Pool, userID int64) (int, error) {
+	var n int
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, q querier, userID int64, hashes []string) error {
+	if _, err := q.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := q.Exec(ctx, `
+		INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at)
+		SELECT $1, UNNEST($2::text[]), NOW()
+	`, userID, hashes); err != nil {
+		return fmt.Errorf("failed to create recovery codes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const patColumns = `id, user_id, created_by, name, token_hash, token_prefix, scopes, created_at, last_used_at, expires_at, revoked_at, acked_seq`
+
+func scanPersonalAccessToken(row pgx.Row) (*models.PersonalAccessToken, error) {
+	var t models.PersonalAccessToken
+	err := row.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.CreatedBy,
+		&t.Name,
+		&t.TokenHash,
+		&t.TokenPrefix,
+		&t.Scopes,
+		&t.CreatedAt,
+		&t.LastUsedAt,
+		&t.ExpiresAt,
+		&t.RevokedAt,
+		&t.AckedSeq,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreatePersonalAccessToken сохраняет хеш нового токена пользователя userID
+func CreatePersonalAccessToken(ctx context.Context, pool *pgxpool.Pool, userID, createdBy int64, name, tokenHash, tokenPrefix string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, error) {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, created_by, name, token_hash, token_prefix, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), $7)
+		RETURNING ` + patColumns
+
+	t, err := scanPersonalAccessToken(pool.QueryRow(ctx, query, userID, createdBy, name, tokenHash, tokenPrefix, scopes, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create personal access token: %w", err)
+	}
+
+	return t, nil
+}
+
+// GetPersonalAccessTokenByHash находит токен по хешу (nil — не найден)
+func GetPersonalAccessTokenByHash(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + patColumns + ` FROM personal_access_tokens WHERE token_hash = $1`
+
+	t, err := scanPersonalAccessToken(pool.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get personal access token: %w", err)
+	}
+
+	return t, nil
+}
+
+// ListPersonalAccessTokens отдаёт неотозванные токены пользователя и его ботов
+func ListPersonalAccessTokens(ctx context.Context, pool *pgxpool.Pool, ownerID int64) ([]models.PersonalAccessToken, error) {
+	query := `
+		SELECT ` + patColumns + ` FROM personal_access_tokens
+		WHERE revoked_at IS NULL
+		  AND user_id IN (SELECT id FROM users WHERE id = $1 OR bot_owner_id = $1)
+		ORDER BY created_at DESC
+	`
+
+	rows, err := pool.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", err)
+	}
+
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		t, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan personal access token: %w", err)
+		}
+		tokens = append(tokens, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokePersonalAccessToken отзывает токен пользователя ownerID или одного из его ботов.
+// false — такого активного токена у него нет.
+func RevokePersonalAccessToken(ctx context.Context, pool *pgxpool.Pool, ownerID, tokenID int64) (bool, error) {
+	tag, err := pool.Exec(ctx, `
+		UPDATE personal_access_tokens SET revoked_at = NOW()
+		WHERE id = $2 AND revoked_at IS NULL
+		  AND user_id IN (SELECT id FROM users WHERE id = $1 OR bot_owner_id = $1)
+	`, ownerID, tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke personal access token: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// TouchPersonalAccessToken обновляет last_used_at (не чаще раза в минуту, чтобы не писать на каждый запрос)
+func TouchPersonalAccessToken(ctx context.Context, pool *pgxpool.Pool, tokenID int64) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE personal_access_tokens SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to touch personal access token: %w", err)
+	}
+	return nil
+}
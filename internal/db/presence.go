@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// SetLastSeen запоминает, когда пользователь последний раз был в сети
+func SetLastSeen(ctx context.Context, pool *pgxpool.Pool, userID int64, at time.Time) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE users SET last_seen_at = $2
+		WHERE id = $1 AND (last_seen_at IS NULL OR last_seen_at < $2)
+	`, userID, at)
+	if err != nil {
+		return fmt.Errorf("failed to set last seen: %w", err)
+	}
+	return nil
+}
+
+// ListConversationPartnerIDs отдаёт id всех, с кем у пользователя есть общая переписка
+func ListConversationPartnerIDs(ctx context.Context, pool *pgxpool.Pool, userID int64) ([]int64, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT DISTINCT other.user_id
+		FROM conversation_members me
+		JOIN conversation_members other ON other.conversation_id = me.conversation_id AND other.user_id <> me.user_id
+		WHERE me.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation partners: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation partner: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list conversation partners: %w", err)
+	}
+	return ids, nil
+}
+
+// SharesConversation проверяет, есть ли у двух пользователей общая переписка
+func SharesConversation(ctx context.Context, pool *pgxpool.Pool, a, b int64) (bool, error) {
+	var ok bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM conversation_members x
+			JOIN conversation_members y ON y.conversation_id = x.conversation_id
+			WHERE x.user_id = $1 AND y.user_id = $2
+		)
+	`, a, b).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("failed to check shared conversation: %w", err)
+	}
+	return ok, nil
+}
+
+// SetHideLastSeen меняет настройку приватности last_seen_at
+func SetHideLastSeen(ctx context.Context, pool *pgxpool.Pool, userID int64, hide bool) (*models.User, error) {
+	query := `
+		UPDATE users SET hide_last_seen = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	user, err := scanUser(pool.QueryRow(ctx, query, userID, hide))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update settings: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// ErrSystemMessage — на служебные сообщения реакции не ставятся
+var ErrSystemMessage = errors.New("cannot react to a system message")
+
+// AddReaction ставит реакцию пользователя на сообщение его переписки.
+// msg=nil — сообщения нет или пользователь не участник; added=false — такая
+// реакция уже стоит. Сообщение блокируется до вставки, чтобы реакция не
+// появилась на нём после параллельного удаления у всех.
+func AddReaction(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64, emoji string) (msg *models.Message, added bool, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	msg, err = getMemberMessage(ctx, tx, messageID, userID, true)
+	if err != nil || msg == nil {
+		return nil, false, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, false, ErrMessageDeleted
+	}
+	if msg.Kind == models.MessageSystem {
+		return nil, false, ErrSystemMessage
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+	`, messageID, userID, emoji)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return msg, tag.RowsAffected() > 0, nil
+}
+
+// RemoveReaction снимает реакцию пользователя. msg=nil — сообщения нет или
+// пользователь не участник; removed=false — такой реакции не было.
+func RemoveReaction(ctx context.Context, pool *pgxpool.Pool, messageID, userID int64, emoji string) (msg *models.Message, removed bool, err error) {
+	msg, err = getMemberMessage(ctx, pool, messageID, userID, false)
+	if err != nil || msg == nil {
+		return nil, false, err
+	}
+
+	tag, err := pool.Exec(ctx, `
+		DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+	`, messageID, userID, emoji)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	return msg, tag.RowsAffected() > 0, nil
+}
+
+// attachReactions заполняет у сообщений счётчики реакций (в порядке первой
+// реакции каждым эмодзи) и отмечает реакции пользователя userID
+func attachReactions(ctx context.Context, pool *pgxpool.Pool, userID int64, msgs []models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(msgs))
+	index := make(map[int64]int, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+		index[m.ID] = i
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $2)
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at), emoji
+	`, ids, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var rc models.ReactionCount
+		if err := rows.Scan(&messageID, &rc.Emoji, &rc.Count, &rc.Me); err != nil {
+			return fmt.Errorf("failed to scan reaction: %w", err)
+		}
+		m := &msgs[index[messageID]]
+		m.Reactions = append(m.Reactions, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list reactions: %w", err)
+	}
+	return nil
+}
+
+// mergeReactions переносит реакции дубликата на основной аккаунт.
+// Вызывается из MergeUsers.
+func mergeReactions(ctx context.Context, tx pgx.Tx, primaryID, secondaryID int64) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+		SELECT message_id, $1, emoji, created_at FROM message_reactions WHERE user_id = $2
+		ON CONFLICT (message_id, user_id, emoji) DO UPDATE SET
+			created_at = LEAST(message_reactions.created_at, EXCLUDED.created_at)
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move reactions: %w", err)
+	}
+	return nil
+}
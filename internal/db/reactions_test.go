@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Повторная постановка и снятие реакции ничего не меняют
+func TestReactionsIdempotent(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	msg := testDirectMessage(t, pool, alice.ID, bob.ID, "hi")
+
+	for i, want := range []bool{true, false} {
+		got, added, err := AddReaction(ctx, pool, msg.ID, bob.ID, "👍")
+		if err != nil || got == nil {
+			t.Fatalf("AddReaction #%d: %v, %v", i, got, err)
+		}
+		if added != want {
+			t.Fatalf("AddReaction #%d: added = %v, want %v", i, added, want)
+		}
+	}
+	if _, _, err := AddReaction(ctx, pool, msg.ID, alice.ID, "👍"); err != nil {
+		t.Fatalf("AddReaction: %v", err)
+	}
+
+	msgs, _, err := ListMessages(ctx, pool, msg.ConversationID, bob.ID, nil, nil, 10)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if r := msgs[0].Reactions; len(r) != 1 || r[0].Emoji != "👍" || r[0].Count != 2 || !r[0].Me {
+		t.Fatalf("unexpected reactions %+v", r)
+	}
+
+	for i, want := range []bool{true, false} {
+		_, removed, err := RemoveReaction(ctx, pool, msg.ID, bob.ID, "👍")
+		if err != nil {
+			t.Fatalf("RemoveReaction #%d: %v", i, err)
+		}
+		if removed != want {
+			t.Fatalf("RemoveReaction #%d: removed = %v, want %v", i, removed, want)
+		}
+	}
+
+	// Посторонний не видит сообщение
+	outsider := testUser(t, pool, "outsider")
+	if got, _, err := AddReaction(ctx, pool, msg.ID, outsider.ID, "👍"); err != nil || got != nil {
+		t.Fatalf("expected no message for outsider, got %v, %v", got, err)
+	}
+}
+
+func TestAddReactionRejectsDeletedAndSystemMessages(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+	msg := testDirectMessage(t, pool, alice.ID, bob.ID, "hi")
+	if _, err := DeleteMessageForEveryone(ctx, pool, msg.ID, alice.ID, time.Hour); err != nil {
+		t.Fatalf("DeleteMessageForEveryone: %v", err)
+	}
+	if _, _, err := AddReaction(ctx, pool, msg.ID, bob.ID, "👍"); !errors.Is(err, ErrMessageDeleted) {
+		t.Fatalf("expected ErrMessageDeleted, got %v", err)
+	}
+
+	_, posted, err := CreateGroup(ctx, pool, alice.ID, "group", nil, []int64{bob.ID}, testMaxMembers)
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if _, _, err := AddReaction(ctx, pool, posted[0].Message.ID, bob.ID, "👍"); !errors.Is(err, ErrSystemMessage) {
+		t.Fatalf("expected ErrSystemMessage, got %v", err)
+	}
+}
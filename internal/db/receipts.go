@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// ReceiptUpdate — новая отметка для отправителя: получатель доставил/прочитал
+// его сообщения в переписке до MessageID включительно
+type ReceiptUpdate struct {
+	ConversationID int64
+	SenderID       int64
+	MessageID      int64
+}
+
+// MarkDelivered отмечает доставленными сообщения ленты пользователя до seq
+// (первое подтверждение с любого его устройства). Отдаёт, кому из отправителей
+// сообщить об этом.
+func MarkDelivered(ctx context.Context, pool *pgxpool.Pool, userID, seq int64, at time.Time) ([]ReceiptUpdate, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var prev int64
+	err = tx.QueryRow(ctx, `
+		UPDATE users u SET delivered_seq = $2
+		FROM (SELECT id, delivered_seq FROM users WHERE id = $1 FOR UPDATE) old
+		WHERE u.id = old.id AND old.delivered_seq < $2 AND $2 <= u.last_seq
+		RETURNING old.delivered_seq
+	`, userID, seq).Scan(&prev)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Уже доставлено раньше (или seq ещё не выдан)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update delivered seq: %w", err)
+	}
+
+	// Служебные сообщения отметок не получают
+	rows, err := tx.Query(ctx, `
+		WITH inserted AS (
+			INSERT INTO message_receipts (message_id, user_id, delivered_at)
+			SELECT m.id, $1, $4
+			FROM message_inbox i
+			JOIN messages m ON m.id = i.message_id
+			WHERE i.user_id = $1 AND i.seq > $2 AND i.seq <= $3 AND m.sender_id <> $1 AND m.kind = 'text'
+			ON CONFLICT (message_id, user_id) DO NOTHING
+			RETURNING message_id
+		)
+		SELECT m.conversation_id, m.sender_id, MAX(m.id)
+		FROM inserted JOIN messages m ON m.id = inserted.message_id
+		GROUP BY m.conversation_id, m.sender_id
+	`, userID, prev, seq, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark delivered: %w", err)
+	}
+	updates, err := collectReceiptUpdates(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark delivered: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return updates, nil
+}
+
+// MarkRead сдвигает отметку прочтения участника до messageID (не дальше последнего
+// сообщения переписки) и отмечает прочитанными чужие сообщения до неё.
+// readUpTo — новая отметка (0, если она не сдвинулась); ok=false — пользователь
+// не участник переписки.
+func MarkRead(ctx context.Context, pool *pgxpool.Pool, conversationID, userID, messageID int64, at time.Time) (updates []ReceiptUpdate, readUpTo int64, ok bool, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var prev int64
+	err = tx.QueryRow(ctx, `
+		SELECT last_read_message_id FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+		FOR UPDATE
+	`, conversationID, userID).Scan(&prev)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, 0, false, nil
+		}
+		return nil, 0, false, fmt.Errorf("failed to get read state: %w", err)
+	}
+
+	var upTo int64
+	err = tx.QueryRow(ctx, `
+		SELECT LEAST($2, COALESCE(MAX(id), 0)) FROM messages WHERE conversation_id = $1
+	`, conversationID, messageID).Scan(&upTo)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get last message: %w", err)
+	}
+	if upTo <= prev {
+		return nil, 0, true, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE conversation_members SET last_read_message_id = $3
+		WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID, upTo); err != nil {
+		return nil, 0, false, fmt.Errorf("failed to update read state: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		WITH upserted AS (
+			INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+			SELECT id, $2, $5, $5 FROM messages
+			WHERE conversation_id = $1 AND id > $3 AND id <= $4 AND sender_id <> $2 AND kind = 'text'
+			ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = EXCLUDED.read_at
+			WHERE message_receipts.read_at IS NULL
+			RETURNING message_id
+		)
+		SELECT m.conversation_id, m.sender_id, MAX(m.id)
+		FROM upserted JOIN messages m ON m.id = upserted.message_id
+		GROUP BY m.conversation_id, m.sender_id
+	`, conversationID, userID, prev, upTo, at)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to mark read: %w", err)
+	}
+	updates, err = collectReceiptUpdates(rows)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to mark read: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return updates, upTo, true, nil
+}
+
+func collectReceiptUpdates(rows pgx.Rows) ([]ReceiptUpdate, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReceiptUpdate, error) {
+		var u ReceiptUpdate
+		err := row.Scan(&u.ConversationID, &u.SenderID, &u.MessageID)
+		return u, err
+	})
+}
+
+// attachReceipts заполняет счётчики отметок у сообщений, а у собственных сообщений userID —
+// ещё и отметки каждого получателя: в большой группе список на каждом сообщении слишком дорог
+func attachReceipts(ctx context.Context, pool *pgxpool.Pool, userID int64, msgs []models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(msgs))
+	index := make(map[int64]int, len(msgs))
+	var own []int64
+	for i, m := range msgs {
+		ids[i] = m.ID
+		index[m.ID] = i
+		if m.SenderID == userID {
+			own = append(own, m.ID)
+		}
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT message_id, COUNT(*), COUNT(read_at) FROM message_receipts
+		WHERE message_id = ANY($1)
+		GROUP BY message_id
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to count receipts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var delivered, read int
+		if err := rows.Scan(&messageID, &delivered, &read); err != nil {
+			return fmt.Errorf("failed to scan receipt counts: %w", err)
+		}
+		m := &msgs[index[messageID]]
+		m.DeliveredCount, m.ReadCount = delivered, read
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to count receipts: %w", err)
+	}
+
+	if len(own) == 0 {
+		return nil
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT message_id, user_id, delivered_at, read_at FROM message_receipts
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, user_id
+	`, own)
+	if err != nil {
+		return fmt.Errorf("failed to list receipts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var rc models.MessageReceipt
+		if err := rows.Scan(&messageID, &rc.UserID, &rc.DeliveredAt, &rc.ReadAt); err != nil {
+			return fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		m := &msgs[index[messageID]]
+		m.Receipts = append(m.Receipts, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list receipts: %w", err)
+	}
+	return nil
+}
+
+// mergeReceipts переносит отметки дубликата на основной аккаунт (берём более ранние времена).
+// Вызывается из MergeUsers после переноса сообщений.
+func mergeReceipts(ctx context.Context, tx pgx.Tx, primaryID, secondaryID int64) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+		SELECT message_id, $1, delivered_at, read_at FROM message_receipts WHERE user_id = $2
+		ON CONFLICT (message_id, user_id) DO UPDATE SET
+			delivered_at = LEAST(message_receipts.delivered_at, EXCLUDED.delivered_at),
+			read_at = LEAST(message_receipts.read_at, EXCLUDED.read_at) -- LEAST пропускает NULL
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move receipts: %w", err)
+	}
+
+	// Отметки на собственных сообщениях (их автором стал основной аккаунт) не нужны
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM message_receipts r USING messages m
+		WHERE m.id = r.message_id AND r.user_id = m.sender_id AND m.sender_id = $1
+	`, primaryID); err != nil {
+		return fmt.Errorf("failed to clean up receipts: %w", err)
+	}
+	return nil
+}
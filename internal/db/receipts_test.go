@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Отметки по получателям видит только автор, остальным достаются счётчики
+func TestListMessagesReceipts(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	alice, bob := testUser(t, pool, "alice"), testUser(t, pool, "bob")
+
+	fromAlice := testDirectMessage(t, pool, alice.ID, bob.ID, "hi bob")
+	testDirectMessage(t, pool, bob.ID, alice.ID, "hi alice")
+
+	if _, _, _, err := MarkRead(ctx, pool, fromAlice.ConversationID, bob.ID, fromAlice.ID, time.Now()); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+
+	for _, viewer := range []int64{alice.ID, bob.ID} {
+		msgs, _, err := ListMessages(ctx, pool, fromAlice.ConversationID, viewer, nil, nil, 10)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(msgs))
+		}
+
+		read := msgs[0]
+		if read.DeliveredCount != 1 || read.ReadCount != 1 {
+			t.Fatalf("viewer %d: expected 1/1 counts, got %d/%d", viewer, read.DeliveredCount, read.ReadCount)
+		}
+		if viewer == alice.ID {
+			if len(read.Receipts) != 1 || read.Receipts[0].UserID != bob.ID || read.Receipts[0].ReadAt == nil {
+				t.Fatalf("expected bob's receipt for the author, got %+v", read.Receipts)
+			}
+		} else if read.Receipts != nil {
+			t.Fatalf("expected no per-member receipts for a recipient, got %+v", read.Receipts)
+		}
+
+		if unread := msgs[1]; unread.DeliveredCount != 0 || unread.ReadCount != 0 || unread.Receipts != nil {
+			t.Fatalf("viewer %d: expected no receipts on unread message, got %+v", viewer, unread)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// ErrRefreshTokenReused возвращается при попытке повторно обменять уже использованный
+// или отозванный refresh-токен. Всё семейство к этому моменту уже отозвано.
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+const refreshTokenColumns = `id, user_id, session_id, family_id, token_hash, created_at, expires_at, used_at, revoked_at`
+
+func scanRefreshToken(row pgx.Row) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := row.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.SessionID,
+		&t.FamilyID,
+		&t.TokenHash,
+		&t.CreatedAt,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateRefreshToken сохраняет хеш нового refresh-токена, выданного сессии
+func CreateRefreshToken(ctx context.Context, pool *pgxpool.Pool, userID, sessionID int64, familyID, tokenHash string, expiresAt time.Time) (*models.RefreshToken, error) {
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, family_id, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		RETURNING ` + refreshTokenColumns
+
+	t, err := scanRefreshToken(pool.QueryRow(ctx, query, userID, sessionID, familyID, tokenHash, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return t, nil
+}
+
+// GetRefreshTokenByHash находит refresh-токен по хешу
+func GetRefreshTokenByHash(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+
+	t, err := scanRefreshToken(pool.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return t, nil
+}
+
+// RotateRefreshToken в одной транзакции помечает старый токен использованным и
+// выпускает новый в том же семействе. Если старый токен уже был использован или
+// отозван, отзывает всё семейство вместе с его сессией и возвращает ErrRefreshTokenReused.
+func RotateRefreshToken(ctx context.Context, pool *pgxpool.Pool, old *models.RefreshToken, newHash string, expiresAt time.Time) (*models.RefreshToken, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`, old.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		// Токен уже обменяли (или отозвали) — кто-то использует его повторно
+		if _, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, old.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+		if old.SessionID != nil {
+			if _, err := tx.Exec(ctx, `
+				UPDATE sessions SET revoked_at = NOW()
+				WHERE id = $1 AND revoked_at IS NULL
+			`, *old.SessionID); err != nil {
+				return nil, fmt.Errorf("failed to revoke session: %w", err)
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("failed to commit tx: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	t, err := scanRefreshToken(tx.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (user_id, session_id, family_id, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		RETURNING `+refreshTokenColumns,
+		old.UserID, old.SessionID, old.FamilyID, newHash, expiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return t, nil
+}
+
+// RevokeRefreshTokenFamily отзывает все токены семейства (токены, выданные до появления сессий)
+func RevokeRefreshTokenFamily(ctx context.Context, pool *pgxpool.Pool, familyID string) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
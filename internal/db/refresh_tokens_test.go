@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Ротация выдаёт новый токен того же семейства, а повторный обмен старого
+// отзывает всё семейство вместе с сессией
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	user := testUser(t, pool, "alice")
+	session, err := CreateSession(ctx, pool, user.ID, "test", "go-test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	first, err := CreateRefreshToken(ctx, pool, user.ID, session.ID, "family-1", "hash-1", expiresAt)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	second, err := RotateRefreshToken(ctx, pool, first, "hash-2", expiresAt)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if second.FamilyID != first.FamilyID || second.SessionID == nil || *second.SessionID != session.ID {
+		t.Fatalf("rotated token left the family or session: %+v", second)
+	}
+
+	// Старый токен предъявлен ещё раз — похоже на кражу
+	if _, err := RotateRefreshToken(ctx, pool, first, "hash-3", expiresAt); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	latest, err := GetRefreshTokenByHash(ctx, pool, "hash-2")
+	if err != nil {
+		t.Fatalf("GetRefreshTokenByHash: %v", err)
+	}
+	if latest.RevokedAt == nil {
+		t.Fatal("expected the whole family to be revoked")
+	}
+	if _, err := RotateRefreshToken(ctx, pool, latest, "hash-4", expiresAt); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected revoked token to be refused, got %v", err)
+	}
+
+	s, err := GetSessionByID(ctx, pool, session.ID)
+	if err != nil {
+		t.Fatalf("GetSessionByID: %v", err)
+	}
+	if s.RevokedAt == nil {
+		t.Fatal("expected the session to be revoked")
+	}
+
+	if leaked, err := GetRefreshTokenByHash(ctx, pool, "hash-3"); err != nil || leaked != nil {
+		t.Fatalf("reuse must not mint a token, got %+v, %v", leaked, err)
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+const sessionColumns = `id, user_id, client_name, user_agent, ip, created_at, last_seen_at, revoked_at, acked_seq`
+
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var s models.Session
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.ClientName,
+		&s.UserAgent,
+		&s.IP,
+		&s.CreatedAt,
+		&s.LastSeenAt,
+		&s.RevokedAt,
+		&s.AckedSeq,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSession регистрирует новое устройство пользователя
+func CreateSession(ctx context.Context, pool *pgxpool.Pool, userID int64, clientName, userAgent, ip string) (*models.Session, error) {
+	query := `
+		INSERT INTO sessions (user_id, client_name, user_agent, ip, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING ` + sessionColumns
+
+	s, err := scanSession(pool.QueryRow(ctx, query, userID, clientName, userAgent, ip))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetSessionByID находит сессию по ID
+func GetSessionByID(ctx context.Context, pool *pgxpool.Pool, sessionID int64) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	s, err := scanSession(pool.QueryRow(ctx, query, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return s, nil
+}
+
+// ListActiveSessions возвращает неотозванные сессии пользователя, последние активные первыми
+func ListActiveSessions(ctx context.Context, pool *pgxpool.Pool, userID int64) ([]models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession обновляет last_seen_at и IP. Пишем не чаще раза в минуту,
+// чтобы не делать UPDATE на каждый запрос.
+func TouchSession(ctx context.Context, pool *pgxpool.Pool, sessionID int64, ip string) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE sessions
+		SET last_seen_at = NOW(), ip = $2
+		WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`, sessionID, ip)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession отзывает сессию пользователя вместе с её refresh-токенами.
+// Возвращает false, если активной сессии с таким ID у пользователя нет.
+func RevokeSession(ctx context.Context, pool *pgxpool.Pool, userID, sessionID int64) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE session_id = $1 AND revoked_at IS NULL
+	`, sessionID); err != nil {
+		return false, fmt.Errorf("failed to revoke session refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return true, nil
+}
+
+// RevokeUserSessions отзывает все сессии и refresh-токены пользователя.
+// Возвращает ID отозванных сессий, чтобы закрыть их открытые сокеты.
+func RevokeUserSessions(ctx context.Context, pool *pgxpool.Pool, userID int64) ([]int64, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return nil, fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// assignSeqs добавляет сообщение в ленты всех участников переписки (включая
+// отправителя — для его других устройств) и отдаёт выданные seq по пользователям.
+func assignSeqs(ctx context.Context, q querier, conversationID, messageID int64) (map[int64]int64, error) {
+	// Блокируем строки пользователей в одном порядке, чтобы параллельные
+	// сообщения в пересекающиеся переписки не взаимоблокировались
+	if _, err := q.Exec(ctx, `
+		SELECT id FROM users
+		WHERE id IN (SELECT user_id FROM conversation_members WHERE conversation_id = $1)
+		ORDER BY id
+		FOR UPDATE
+	`, conversationID); err != nil {
+		return nil, fmt.Errorf("failed to lock inbox owners: %w", err)
+	}
+
+	rows, err := q.Query(ctx, `
+		WITH bumped AS (
+			UPDATE users SET last_seq = last_seq + 1
+			WHERE id IN (SELECT user_id FROM conversation_members WHERE conversation_id = $1)
+			RETURNING id, last_seq
+		)
+		INSERT INTO message_inbox (user_id, seq, message_id)
+		SELECT id, last_seq, $2 FROM bumped
+		RETURNING user_id, seq
+	`, conversationID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign seqs: %w", err)
+	}
+	defer rows.Close()
+
+	seqs := map[int64]int64{}
+	for rows.Next() {
+		var userID, seq int64
+		if err := rows.Scan(&userID, &seq); err != nil {
+			return nil, fmt.Errorf("failed to scan seq: %w", err)
+		}
+		seqs[userID] = seq
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to assign seqs: %w", err)
+	}
+	return seqs, nil
+}
+
+// ListInbox отдаёт до limit сообщений ленты пользователя с seq больше since,
+// по возрастанию seq (у каждого заполнен Seq). hasMore — есть ли сообщения дальше.
+func ListInbox(ctx context.Context, pool *pgxpool.Pool, userID, since int64, limit int) (msgs []models.Message, hasMore bool, err error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+messageColumns+`, i.seq
+		FROM message_inbox i
+		JOIN messages ON messages.id = i.message_id
+		WHERE i.user_id = $1 AND i.seq > $2
+		ORDER BY i.seq
+		LIMIT $3
+	`, userID, since, limit+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list inbox: %w", err)
+	}
+	defer rows.Close()
+
+	msgs = []models.Message{}
+	for rows.Next() {
+		var seq int64
+		m, err := scanMessage(rows, &seq)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan message: %w", err)
+		}
+		m.Seq = seq
+		msgs = append(msgs, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to list inbox: %w", err)
+	}
+
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		hasMore = true
+	}
+	if err := applyHidden(ctx, pool, userID, msgs); err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, nil
+}
+
+// GetLastSeq отдаёт последний выданный пользователю seq
+func GetLastSeq(ctx context.Context, pool *pgxpool.Pool, userID int64) (int64, error) {
+	var seq int64
+	err := pool.QueryRow(ctx, `SELECT last_seq FROM users WHERE id = $1`, userID).Scan(&seq)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get last seq: %w", err)
+	}
+	return seq, nil
+}
+
+// AckSessionSeq запоминает, что устройство получило сообщения до seq включительно.
+// Подтверждение назад или дальше выданных seq игнорируется.
+func AckSessionSeq(ctx context.Context, pool *pgxpool.Pool, sessionID, seq int64) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE sessions s SET acked_seq = $2
+		FROM users u
+		WHERE s.id = $1 AND u.id = s.user_id AND s.acked_seq < $2 AND $2 <= u.last_seq
+	`, sessionID, seq)
+	if err != nil {
+		return fmt.Errorf("failed to ack session seq: %w", err)
+	}
+	return nil
+}
+
+// AckTokenSeq — то же для клиента с персональным токеном
+func AckTokenSeq(ctx context.Context, pool *pgxpool.Pool, tokenID, seq int64) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE personal_access_tokens t SET acked_seq = $2
+		FROM users u
+		WHERE t.id = $1 AND u.id = t.user_id AND t.acked_seq < $2 AND $2 <= u.last_seq
+	`, tokenID, seq)
+	if err != nil {
+		return fmt.Errorf("failed to ack token seq: %w", err)
+	}
+	return nil
+}
+
+// mergeInbox дописывает в ленту основного аккаунта сообщения из ленты дубликата,
+// которых у него ещё нет, с новыми seq (в прежнем порядке), и сдвигает last_seq.
+// Иначе синхронизация устройств основного аккаунта не увидит перенесённую историю.
+// Вызывается из MergeUsers после переноса переписок, строки пользователей уже заблокированы.
+func mergeInbox(ctx context.Context, tx pgx.Tx, primaryID, secondaryID int64) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO message_inbox (user_id, seq, message_id)
+		SELECT $1, u.last_seq + moved.n, moved.message_id
+		FROM (
+			SELECT s.message_id, ROW_NUMBER() OVER (ORDER BY s.seq) AS n
+			FROM message_inbox s
+			WHERE s.user_id = $2 AND NOT EXISTS (
+				SELECT 1 FROM message_inbox p WHERE p.user_id = $1 AND p.message_id = s.message_id
+			)
+		) moved, users u
+		WHERE u.id = $1
+	`, primaryID, secondaryID); err != nil {
+		return fmt.Errorf("failed to move inbox: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET last_seq = GREATEST(last_seq, (
+			SELECT COALESCE(MAX(seq), 0) FROM message_inbox WHERE user_id = $1
+		))
+		WHERE id = $1
+	`, primaryID); err != nil {
+		return fmt.Errorf("failed to update last seq: %w", err)
+	}
+	return nil
+}
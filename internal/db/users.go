@@ -6,81 +6,49 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yeoboseyo/server/internal/models"
 )
 
-// GetOrCreateUser находит пользователя по google_id или создаёт нового
-// Возвращает пользователя и флаг, указывающий, был ли он создан (true) или найден (false)
-func GetOrCreateUser(ctx context.Context, pool *pgxpool.Pool, googleID, email, name, picture string) (*models.User, bool, error) {
-	// Сначала пытаемся найти существующего пользователя
-	user, err := GetUserByGoogleID(ctx, pool, googleID)
-	if err == nil && user != nil {
-		// Пользователь найден, обновляем информацию (на случай, если изменились email, name, picture)
-		updatedUser, err := UpdateUser(ctx, pool, user.ID, email, name, picture)
-		if err != nil {
-			return user, false, nil // Возвращаем старую версию, если обновление не удалось
-		}
-		return updatedUser, false, nil
-	}
-
-	// Пользователь не найден, создаём нового
-	newUser, err := CreateUser(ctx, pool, googleID, email, name, picture)
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to create user: %w", err)
-	}
-
-	return newUser, true, nil
+// querier — общее у пула и транзакции, чтобы одни и те же запросы работали в обоих
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
-// GetUserByGoogleID находит пользователя по Google ID
-func GetUserByGoogleID(ctx context.Context, pool *pgxpool.Pool, googleID string) (*models.User, error) {
-	var user models.User
-	query := `
-		SELECT id, google_id, email, name, picture, created_at, updated_at
-		FROM users
-		WHERE google_id = $1
-	`
+const userColumns = `id, email, name, picture, is_bot, bot_owner_id, created_at, updated_at, last_seen_at, hide_last_seen`
 
-	err := pool.QueryRow(ctx, query, googleID).Scan(
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
 		&user.ID,
-		&user.GoogleID,
 		&user.Email,
 		&user.Name,
 		&user.Picture,
+		&user.IsBot,
+		&user.BotOwnerID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.LastSeenAt,
+		&user.HideLastSeen,
 	)
-
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil // Пользователь не найден
-		}
-		return nil, fmt.Errorf("failed to get user by google_id: %w", err)
+		return nil, err
 	}
-
 	return &user, nil
 }
 
 // GetUserByID находит пользователя по ID
 func GetUserByID(ctx context.Context, pool *pgxpool.Pool, userID int64) (*models.User, error) {
-	var user models.User
-	query := `
-		SELECT id, google_id, email, name, picture, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
+	return getUserByID(ctx, pool, userID)
+}
 
-	err := pool.QueryRow(ctx, query, userID).Scan(
-		&user.ID,
-		&user.GoogleID,
-		&user.Email,
-		&user.Name,
-		&user.Picture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+func getUserByID(ctx context.Context, q querier, userID int64) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
+	user, err := scanUser(q.QueryRow(ctx, query, userID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -88,55 +56,44 @@ func GetUserByID(ctx context.Context, pool *pgxpool.Pool, userID int64) (*models
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // CreateUser создаёт нового пользователя
-func CreateUser(ctx context.Context, pool *pgxpool.Pool, googleID, email, name, picture string) (*models.User, error) {
-	var user models.User
-	query := `
-		INSERT INTO users (google_id, email, name, picture, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, google_id, email, name, picture, created_at, updated_at
-	`
+func CreateUser(ctx context.Context, pool *pgxpool.Pool, email, name, picture string) (*models.User, error) {
+	return createUser(ctx, pool, email, name, picture)
+}
 
-	err := pool.QueryRow(ctx, query, googleID, email, name, picture).Scan(
-		&user.ID,
-		&user.GoogleID,
-		&user.Email,
-		&user.Name,
-		&user.Picture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+func createUser(ctx context.Context, q querier, email, name, picture string) (*models.User, error) {
+	query := `
+		INSERT INTO users (email, name, picture, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING ` + userColumns
 
+	user, err := scanUser(q.QueryRow(ctx, query, email, name, picture))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// UpdateUser обновляет информацию о пользователе
+// UpdateUser обновляет информацию о пользователе (пустые значения не затирают текущие)
 func UpdateUser(ctx context.Context, pool *pgxpool.Pool, userID int64, email, name, picture string) (*models.User, error) {
-	var user models.User
+	return updateUser(ctx, pool, userID, email, name, picture)
+}
+
+func updateUser(ctx context.Context, q querier, userID int64, email, name, picture string) (*models.User, error) {
 	query := `
 		UPDATE users
-		SET email = $2, name = $3, picture = $4, updated_at = NOW()
+		SET email = COALESCE(NULLIF($2, ''), email),
+		    name = COALESCE(NULLIF($3, ''), name),
+		    picture = COALESCE(NULLIF($4, ''), picture),
+		    updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, google_id, email, name, picture, created_at, updated_at
-	`
-
-	err := pool.QueryRow(ctx, query, userID, email, name, picture).Scan(
-		&user.ID,
-		&user.GoogleID,
-		&user.Email,
-		&user.Name,
-		&user.Picture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+		RETURNING ` + userColumns
 
+	user, err := scanUser(q.QueryRow(ctx, query, userID, email, name, picture))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -144,6 +101,5 @@ func UpdateUser(ctx context.Context, pool *pgxpool.Pool, userID int64, email, na
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
-
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// adminUserIDs — пользователи с доступом к /api/admin (ADMIN_USER_IDS, id через запятую)
+var adminUserIDs = map[int64]bool{}
+
+func init() {
+	for _, v := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Warn().Str("value", v).Msg("invalid id in ADMIN_USER_IDS, skipping")
+			continue
+		}
+		adminUserIDs[id] = true
+	}
+}
+
+// AdminMiddleware пускает только администраторов; ставится после AuthMiddleware
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r.Context())
+		if user == nil || !adminUserIDs[user.ID] {
+			writeError(w, http.StatusForbidden, "admin only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type connectionStatsResponse struct {
+	Connections int `json:"connections"` // открытые сокеты этого инстанса
+	Users       int `json:"users"`       // пользователи хотя бы с одним сокетом
+}
+
+// ConnectionStatsHandler отдаёт число живых сокетов на этом инстансе
+func ConnectionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	connections, users := wsHub.Stats()
+	writeJSON(w, http.StatusOK, connectionStatsResponse{Connections: connections, Users: users})
+}
@@ -0,0 +1,228 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/identity"
+	"github.com/yeoboseyo/server/internal/mail"
+)
+
+// Провайдер в user_identities для входа по email
+const emailProvider = "email"
+
+const (
+	// Сколько раз можно ошибиться с кодом, прежде чем он сгорит
+	emailCodeMaxAttempts = 5
+)
+
+var (
+	// Время жизни ссылки и кода
+	emailLoginTTL = envDuration("EMAIL_LOGIN_TTL", 15*time.Minute)
+	// Страница фронтенда, которая получает ?token= и вызывает /auth/email/verify
+	emailLoginURL = os.Getenv("EMAIL_LOGIN_URL")
+
+	// Лимиты на отправку писем: на адрес и на IP
+	emailStartPerEmail = newRateLimiter(5, time.Hour)
+	emailStartPerIP    = newRateLimiter(20, time.Hour)
+	// Лимит на попытки подтверждения с одного IP
+	emailVerifyPerIP = newRateLimiter(30, 15*time.Minute)
+)
+
+type emailStartRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailLoginStartHandler отправляет на email одноразовую ссылку и код для входа.
+// Всегда отвечает 202, не раскрывая, есть ли такой пользователь.
+func EmailLoginStartHandler(w http.ResponseWriter, r *http.Request) {
+	var req emailStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid email")
+		return
+	}
+
+	ip := clientIP(r)
+	if !emailStartPerIP.Allow(ip) || !emailStartPerEmail.Allow(email) {
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+	code, err := randomDigits(6)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+
+	if err := db.CreateEmailLoginToken(r.Context(), pool, email, hashToken(token), hashEmailCode(email, code), ip, time.Now().Add(emailLoginTTL)); err != nil {
+		log.Error().Err(err).Msg("failed to create email login token")
+		writeError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+
+	if err := mailSender.Send(r.Context(), emailLoginMessage(email, token, code)); err != nil {
+		log.Error().Err(err).Msg("failed to send email login message")
+		writeError(w, http.StatusBadGateway, "failed to send email")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"status":     "sent",
+		"expires_in": int64(emailLoginTTL.Seconds()),
+	})
+}
+
+type emailVerifyRequest struct {
+	Token string `json:"token,omitempty"` // из ссылки
+	Email string `json:"email,omitempty"` // или email + код из письма
+	Code  string `json:"code,omitempty"`
+}
+
+// EmailLoginVerifyHandler обменивает ссылку или код из письма на наши токены
+// (тот же ответ, что и при входе через провайдера).
+func EmailLoginVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var req emailVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if !emailVerifyPerIP.Allow(clientIP(r)) {
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var email string
+	switch {
+	case req.Token != "":
+		var err error
+		email, err = db.ConsumeEmailLoginToken(r.Context(), pool, hashToken(req.Token))
+		if err != nil {
+			log.Error().Err(err).Msg("failed to consume email login token")
+			writeError(w, http.StatusInternalServerError, "failed to verify")
+			return
+		}
+
+	case req.Email != "" && req.Code != "":
+		normalized, ok := normalizeEmail(req.Email)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid email")
+			return
+		}
+		valid, err := db.ConsumeEmailLoginCode(r.Context(), pool, normalized, hashEmailCode(normalized, strings.TrimSpace(req.Code)), emailCodeMaxAttempts)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to consume email login code")
+			writeError(w, http.StatusInternalServerError, "failed to verify")
+			return
+		}
+		if valid {
+			email = normalized
+		}
+
+	default:
+		writeError(w, http.StatusBadRequest, "token or email and code required")
+		return
+	}
+
+	if email == "" {
+		writeError(w, http.StatusUnauthorized, "invalid or expired code")
+		return
+	}
+
+	completeLogin(w, r, &identity.Identity{
+		Provider:      emailProvider,
+		Subject:       email,
+		Email:         email,
+		EmailVerified: true,
+	}, "")
+}
+
+// normalizeEmail приводит адрес к нижнему регистру и проверяет, что это голый адрес без имени
+func normalizeEmail(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > 254 {
+		return "", false
+	}
+
+	addr, err := netmail.ParseAddress(raw)
+	if err != nil || addr.Name != "" || addr.Address != raw {
+		return "", false
+	}
+
+	return strings.ToLower(addr.Address), true
+}
+
+// hashEmailCode привязывает хеш кода к адресу, чтобы одинаковые коды у разных людей не совпадали
+func hashEmailCode(email, code string) string {
+	return hashToken(email + ":" + code)
+}
+
+// randomDigits — криптостойкий числовой код заданной длины
+func randomDigits(n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate code: %w", err)
+		}
+		b.WriteByte(byte('0' + d.Int64()))
+	}
+	return b.String(), nil
+}
+
+func emailLoginMessage(email, token, code string) mail.Message {
+	var text strings.Builder
+	text.WriteString("Hi!\n\n")
+	if emailLoginURL != "" {
+		link := emailLoginURL + "?token=" + url.QueryEscape(token)
+		if strings.Contains(emailLoginURL, "?") {
+			link = emailLoginURL + "&token=" + url.QueryEscape(token)
+		}
+		fmt.Fprintf(&text, "Open this link to sign in to Yeoboseyo:\n%s\n\n", link)
+		fmt.Fprintf(&text, "Or enter this code: %s\n\n", code)
+	} else {
+		fmt.Fprintf(&text, "Your Yeoboseyo sign-in code: %s\n\n", code)
+	}
+	fmt.Fprintf(&text, "The code expires in %d minutes and can be used only once.\n", int(emailLoginTTL.Minutes()))
+	text.WriteString("If you didn't request it, just ignore this email.\n")
+
+	return mail.Message{
+		To:      email,
+		Subject: "Your Yeoboseyo sign-in link",
+		Text:    text.String(),
+	}
+}
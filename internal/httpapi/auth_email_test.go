@@ -0,0 +1,237 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeoboseyo/server/internal/mail"
+)
+
+// useTestMailDir складывает письма теста в .eml-файлы временного каталога
+func useTestMailDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	prev := mailSender
+	SetMailSender(&mail.FileSender{From: "test@localhost", Dir: dir})
+	t.Cleanup(func() { SetMailSender(prev) })
+	return dir
+}
+
+// useTestLimiters подменяет лимиты входа по email на время теста
+func useTestLimiters(t *testing.T, perEmail, perIP, verifyPerIP *rateLimiter) {
+	t.Helper()
+	prevEmail, prevIP, prevVerify := emailStartPerEmail, emailStartPerIP, emailVerifyPerIP
+	emailStartPerEmail, emailStartPerIP, emailVerifyPerIP = perEmail, perIP, verifyPerIP
+	t.Cleanup(func() {
+		emailStartPerEmail, emailStartPerIP, emailVerifyPerIP = prevEmail, prevIP, prevVerify
+	})
+}
+
+func postJSON(handler http.HandlerFunc, path, ip string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	r := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	r.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+var (
+	mailCodeRe  = regexp.MustCompile(`code: (\d{6})`)
+	mailTokenRe = regexp.MustCompile(`token=([A-Za-z0-9_%-]+)`)
+)
+
+// mailText разбирает письмо и отдаёт раскодированный текст
+func mailText(t *testing.T, raw []byte) string {
+	t.Helper()
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("decode mail body: %v", err)
+	}
+	return string(body)
+}
+
+// lastMail отдаёт текст единственного нового письма в каталоге и удаляет его
+func lastMail(t *testing.T, dir string) string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected exactly one mail, got %v, %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read mail: %v", err)
+	}
+	os.Remove(files[0])
+	return mailText(t, data)
+}
+
+func TestEmailLoginStartRateLimits(t *testing.T) {
+	useTestLimiters(t, newRateLimiter(1, time.Hour), newRateLimiter(2, time.Hour), newRateLimiter(30, time.Minute))
+
+	// Без БД запрос в пределах лимита доходит до неё и падает, сверх лимита — 429
+	start := func(email, ip string) int {
+		return postJSON(EmailLoginStartHandler, "/auth/email/start", ip, emailStartRequest{Email: email}).Code
+	}
+
+	if code := start("a@example.com", "10.0.0.1"); code == http.StatusTooManyRequests {
+		t.Fatal("first request must not be limited")
+	}
+	// Тот же адрес в другом регистре с другого IP
+	if code := start("A@example.com", "10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("per-email limit: expected 429, got %d", code)
+	}
+	if code := start("b@example.com", "10.0.0.1"); code == http.StatusTooManyRequests {
+		t.Fatal("another email from the same IP must be allowed within the IP limit")
+	}
+	if code := start("c@example.com", "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("per-IP limit: expected 429, got %d", code)
+	}
+	if code := start("not an email", "10.0.0.3"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid email, got %d", code)
+	}
+}
+
+func TestEmailLoginVerifyRateLimit(t *testing.T) {
+	useTestLimiters(t, newRateLimiter(5, time.Hour), newRateLimiter(20, time.Hour), newRateLimiter(1, time.Hour))
+
+	req := emailVerifyRequest{Email: "a@example.com", Code: "000000"}
+	postJSON(EmailLoginVerifyHandler, "/auth/email/verify", "10.0.0.1", req)
+	if w := postJSON(EmailLoginVerifyHandler, "/auth/email/verify", "10.0.0.1", req); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+}
+
+func TestEmailLoginMessage(t *testing.T) {
+	dir := useTestMailDir(t)
+
+	prevURL := emailLoginURL
+	t.Cleanup(func() { emailLoginURL = prevURL })
+
+	send := func(token string) {
+		t.Helper()
+		if err := mailSender.Send(t.Context(), emailLoginMessage("a@example.com", token, "123456")); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	emailLoginURL = ""
+	send("tok")
+	if s := lastMail(t, dir); !strings.Contains(s, "code: 123456") || strings.Contains(s, "token=") {
+		t.Fatalf("unexpected code-only mail:\n%s", s)
+	}
+
+	// Ссылка дописывает token к уже имеющимся параметрам и экранирует его
+	emailLoginURL = "https://app.example.com/login?from=mail"
+	send("a+b")
+	if s := lastMail(t, dir); !strings.Contains(s, "https://app.example.com/login?from=mail&token=a%2Bb\r\n") || !strings.Contains(s, "code: 123456") {
+		t.Fatalf("unexpected link mail:\n%s", s)
+	}
+}
+
+// Полный вход по коду через file-отправщика: ошибки сжигают код, свежий код пускает
+func TestEmailLoginByCode(t *testing.T) {
+	useTestDB(t)
+	useTestKeys(t, "test-secret-test-secret-test-secret")
+	dir := useTestMailDir(t)
+	useTestLimiters(t, newRateLimiter(5, time.Hour), newRateLimiter(20, time.Hour), newRateLimiter(30, time.Minute))
+
+	prevURL := emailLoginURL
+	emailLoginURL = ""
+	t.Cleanup(func() { emailLoginURL = prevURL })
+
+	start := func() string {
+		t.Helper()
+		if w := postJSON(EmailLoginStartHandler, "/auth/email/start", "10.0.0.1", emailStartRequest{Email: "Alice@Example.com"}); w.Code != http.StatusAccepted {
+			t.Fatalf("start: expected 202, got %d: %s", w.Code, w.Body)
+		}
+		m := mailCodeRe.FindStringSubmatch(lastMail(t, dir))
+		if m == nil {
+			t.Fatal("no code in mail")
+		}
+		return m[1]
+	}
+	verify := func(code string) *httptest.ResponseRecorder {
+		return postJSON(EmailLoginVerifyHandler, "/auth/email/verify", "10.0.0.1", emailVerifyRequest{Email: "alice@example.com", Code: code})
+	}
+	wrong := func(code string) string {
+		if code == "000000" {
+			return "111111"
+		}
+		return "000000"
+	}
+
+	code := start()
+	for i := range emailCodeMaxAttempts {
+		if w := verify(wrong(code)); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong code #%d: expected 401, got %d", i, w.Code)
+		}
+	}
+	if w := verify(code); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected code to burn after %d attempts, got %d", emailCodeMaxAttempts, w.Code)
+	}
+
+	code = start()
+	w := verify(code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp authResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Token == "" || resp.User.Email != "alice@example.com" {
+		t.Fatalf("unexpected login response %s", w.Body)
+	}
+
+	// Код одноразовый
+	if w := verify(code); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused code: expected 401, got %d", w.Code)
+	}
+}
+
+func TestEmailLoginByLink(t *testing.T) {
+	useTestDB(t)
+	useTestKeys(t, "test-secret-test-secret-test-secret")
+	dir := useTestMailDir(t)
+	useTestLimiters(t, newRateLimiter(5, time.Hour), newRateLimiter(20, time.Hour), newRateLimiter(30, time.Minute))
+
+	prevURL := emailLoginURL
+	emailLoginURL = "https://app.example.com/login"
+	t.Cleanup(func() { emailLoginURL = prevURL })
+
+	if w := postJSON(EmailLoginStartHandler, "/auth/email/start", "10.0.0.1", emailStartRequest{Email: "bob@example.com"}); w.Code != http.StatusAccepted {
+		t.Fatalf("start: expected 202, got %d", w.Code)
+	}
+	m := mailTokenRe.FindStringSubmatch(lastMail(t, dir))
+	if m == nil {
+		t.Fatal("no link in mail")
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+
+	verify := func() int {
+		return postJSON(EmailLoginVerifyHandler, "/auth/email/verify", "10.0.0.1", emailVerifyRequest{Token: token}).Code
+	}
+	if code := verify(); code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d", code)
+	}
+	if code := verify(); code != http.StatusUnauthorized {
+		t.Fatalf("reused link: expected 401, got %d", code)
+	}
+}
@@ -0,0 +1,224 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+	tokenContextKey
+)
+
+// accessClaims — содержимое нашего JWT, который выдают хендлеры авторизации.
+type accessClaims struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID int64  `json:"sid"`
+	Type      string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// AuthMiddleware проверяет JWT из заголовка Authorization: Bearer ...,
+// убеждается, что его сессия не отозвана, загружает пользователя из БД
+// и кладёт пользователя и сессию в контекст запроса.
+// Вместо JWT принимает персональный токен (ybs_pat_...) — тогда сессии нет,
+// а маршрут должен быть разрешён токенам и покрыт их scope'ами.
+// При отсутствии, истечении, подделке или отзыве токена отвечает JSON 401.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := bearerToken(r)
+		if raw == "" {
+			writeError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		auth, authErr := authenticate(r, raw)
+		if authErr != nil {
+			writeError(w, authErr.status, authErr.msg)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.withContext(r.Context())))
+	})
+}
+
+// authInfo — кто пришёл с токеном: пользователь и либо сессия (JWT), либо персональный токен
+type authInfo struct {
+	user      *models.User
+	session   *models.Session
+	pat       *models.PersonalAccessToken
+	expiresAt time.Time // когда токен перестанет действовать (нулевое — бессрочный)
+}
+
+func (a *authInfo) withContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, a.user)
+	if a.session != nil {
+		ctx = context.WithValue(ctx, sessionContextKey, a.session)
+	}
+	if a.pat != nil {
+		ctx = context.WithValue(ctx, tokenContextKey, a.pat)
+	}
+	return ctx
+}
+
+// authenticate проверяет токен (наш JWT или персональный) и загружает пользователя.
+// Используется AuthMiddleware и WebSocket'ом, который может получить токен первым кадром.
+func authenticate(r *http.Request, raw string) (*authInfo, *apiError) {
+	pool := DB()
+	if pool == nil {
+		return nil, &apiError{http.StatusInternalServerError, "database not initialized"}
+	}
+
+	if strings.HasPrefix(raw, patPrefix) {
+		return authenticatePersonalToken(r, raw)
+	}
+
+	claims, err := parseAccessToken(raw)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, &apiError{http.StatusUnauthorized, "token expired"}
+		}
+		return nil, &apiError{http.StatusUnauthorized, "invalid token"}
+	}
+
+	session, err := db.GetSessionByID(r.Context(), pool, claims.SessionID)
+	if err != nil {
+		log.Error().Err(err).Int64("session_id", claims.SessionID).Msg("failed to load session for token")
+		return nil, &apiError{http.StatusInternalServerError, "failed to load session"}
+	}
+	if session == nil || session.UserID != claims.UserID || session.RevokedAt != nil {
+		return nil, &apiError{http.StatusUnauthorized, "session revoked"}
+	}
+
+	if err := db.TouchSession(r.Context(), pool, session.ID, clientIP(r)); err != nil {
+		log.Warn().Err(err).Int64("session_id", session.ID).Msg("failed to touch session")
+	}
+
+	user, authErr := loadTokenUser(r, claims.UserID)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	return &authInfo{user: user, session: session, expiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// authenticatePersonalToken — ветка authenticate для персональных токенов:
+// маршрут должен быть разрешён токенам и покрыт их scope'ами
+func authenticatePersonalToken(r *http.Request, raw string) (*authInfo, *apiError) {
+	pool := DB()
+
+	pat, err := db.GetPersonalAccessTokenByHash(r.Context(), pool, hashToken(raw))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load personal access token")
+		return nil, &apiError{http.StatusInternalServerError, "failed to load token"}
+	}
+	if pat == nil || !pat.Active(time.Now()) {
+		return nil, &apiError{http.StatusUnauthorized, "invalid token"}
+	}
+
+	if ok, msg := checkTokenScope(r, pat); !ok {
+		return nil, &apiError{http.StatusForbidden, msg}
+	}
+
+	if err := db.TouchPersonalAccessToken(r.Context(), pool, pat.ID); err != nil {
+		log.Warn().Err(err).Int64("token_id", pat.ID).Msg("failed to touch personal access token")
+	}
+
+	user, authErr := loadTokenUser(r, pat.UserID)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	auth := &authInfo{user: user, pat: pat}
+	if pat.ExpiresAt != nil {
+		auth.expiresAt = *pat.ExpiresAt
+	}
+	return auth, nil
+}
+
+func loadTokenUser(r *http.Request, userID int64) (*models.User, *apiError) {
+	user, err := db.GetUserByID(r.Context(), DB(), userID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("failed to load user for token")
+		return nil, &apiError{http.StatusInternalServerError, "failed to load user"}
+	}
+	if user == nil {
+		return nil, &apiError{http.StatusUnauthorized, "user not found"}
+	}
+	return user, nil
+}
+
+// CurrentUser возвращает пользователя, положенного в контекст AuthMiddleware.
+func CurrentUser(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey).(*models.User)
+	return user
+}
+
+// CurrentSession возвращает сессию (устройство), которой выдан токен текущего запроса.
+// nil, если запрос пришёл с персональным токеном.
+func CurrentSession(ctx context.Context) *models.Session {
+	session, _ := ctx.Value(sessionContextKey).(*models.Session)
+	return session
+}
+
+// CurrentToken возвращает персональный токен запроса (nil для интерактивной сессии).
+func CurrentToken(ctx context.Context) *models.PersonalAccessToken {
+	pat, _ := ctx.Value(tokenContextKey).(*models.PersonalAccessToken)
+	return pat
+}
+
+// bearerToken достаёт токен из Authorization: Bearer ...
+// Браузер не умеет ставить заголовки на WebSocket, поэтому для апгрейда
+// допускаем токен в query-параметре access_token.
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		return r.URL.Query().Get("access_token")
+	}
+
+	return ""
+}
+
+// parseAccessToken проверяет подпись и срок действия нашего JWT.
+func parseAccessToken(raw string) (*accessClaims, error) {
+	claims := &accessClaims{}
+	err := parseJWT(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != tokenTypeAccess {
+		return nil, errors.New("not an access token")
+	}
+
+	if claims.UserID == 0 {
+		return nil, errors.New("missing user_id claim")
+	}
+
+	if claims.SessionID == 0 {
+		return nil, errors.New("missing sid claim")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yeoboseyo/server/internal/jwtkeys"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// useTestKeys подписывает токены теста HMAC-ключом и восстанавливает прежние ключи
+func useTestKeys(t *testing.T, secret string) {
+	t.Helper()
+	ks, err := jwtkeys.New("test", jwtkeys.NewHMACKey("test", []byte(secret)))
+	if err != nil {
+		t.Fatalf("jwtkeys.New: %v", err)
+	}
+	prev := signingKeys
+	SetKeySet(ks)
+	t.Cleanup(func() { SetKeySet(prev) })
+}
+
+func TestParseAccessToken(t *testing.T) {
+	useTestKeys(t, "test-secret-test-secret-test-secret")
+
+	raw, err := issueAccessToken(&models.User{ID: 7, Email: "a@example.com"}, 3)
+	if err != nil {
+		t.Fatalf("issueAccessToken: %v", err)
+	}
+	claims, err := parseAccessToken(raw)
+	if err != nil {
+		t.Fatalf("parseAccessToken: %v", err)
+	}
+	if claims.UserID != 7 || claims.SessionID != 3 || claims.Email != "a@example.com" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		t.Helper()
+		raw, err := signJWT(claims)
+		if err != nil {
+			t.Fatalf("signJWT: %v", err)
+		}
+		return raw
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+
+	cases := map[string]string{
+		"refresh type":   sign(jwt.MapClaims{"user_id": 7, "sid": 3, "typ": "refresh", "exp": exp}),
+		"mfa type":       sign(jwt.MapClaims{"user_id": 7, "sid": 3, "typ": tokenTypeMFAPending, "exp": exp}),
+		"missing user":   sign(jwt.MapClaims{"sid": 3, "typ": tokenTypeAccess, "exp": exp}),
+		"missing sid":    sign(jwt.MapClaims{"user_id": 7, "typ": tokenTypeAccess, "exp": exp}),
+		"missing exp":    sign(jwt.MapClaims{"user_id": 7, "sid": 3, "typ": tokenTypeAccess}),
+		"not a jwt":      "garbage",
+		"tampered token": raw[:len(raw)-2] + "xx",
+	}
+	for name, raw := range cases {
+		if _, err := parseAccessToken(raw); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	expired := sign(jwt.MapClaims{"user_id": 7, "sid": 3, "typ": tokenTypeAccess, "exp": time.Now().Add(-time.Minute).Unix()})
+	if _, err := parseAccessToken(expired); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+
+	// Токен, подписанный чужим секретом, не принимается
+	useTestKeys(t, "another-secret-another-secret-another")
+	if _, err := parseAccessToken(raw); err == nil {
+		t.Fatal("expected token signed with another key to be rejected")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		query  string
+		ws     bool
+		want   string
+	}{
+		{"bearer", "Bearer abc", "", false, "abc"},
+		{"scheme is case-insensitive", "bearer abc", "", false, "abc"},
+		{"other scheme", "Basic abc", "", false, ""},
+		{"no scheme", "abc", "", false, ""},
+		{"query ignored without upgrade", "", "abc", false, ""},
+		{"query on websocket upgrade", "", "abc", true, "abc"},
+		{"header wins over query", "Bearer abc", "def", true, "abc"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/api/ws?access_token="+c.query, nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		if c.ws {
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+		}
+		if got := bearerToken(r); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAuthMiddlewareMissingToken(t *testing.T) {
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called without a token")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/me", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/identity"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// providerFromRequest достаёт настроенного провайдера по {provider} из пути
+func providerFromRequest(w http.ResponseWriter, r *http.Request) (identity.Provider, bool) {
+	p, err := identityProviders.Get(mux.Vars(r)["provider"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "unknown provider")
+		return nil, false
+	}
+	return p, true
+}
+
+// ProviderLoginHandler: редиректит на страницу логина провайдера со случайным state, nonce и PKCE.
+// Необязательный ?return_to= (только с разрешённых origin'ов) — куда вернуть пользователя после логина.
+func ProviderLoginHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	returnTo := r.URL.Query().Get("return_to")
+	if returnTo != "" && !validReturnTo(returnTo) {
+		writeError(w, http.StatusBadRequest, "return_to is not allowed")
+		return
+	}
+
+	st, err := newOAuthState(p.Name(), returnTo)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+
+	url, err := p.AuthCodeURL(r.Context(), st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		log.Error().Err(err).Str("provider", p.Name()).Msg("failed to build auth url")
+		writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	if err := setOAuthStateCookie(w, p, st); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+}
+
+// ProviderCallbackHandler: проверяет state, обменивает код на токены провайдера (с PKCE verifier
+// и проверкой nonce), ищет/создаёт пользователя в БД и выдаёт свой JWT/сессию.
+// Принимает и GET, и POST (провайдеры с response_mode=form_post).
+func ProviderCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := popOAuthState(w, r, p)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid oauth state: "+err.Error())
+		return
+	}
+
+	if e := r.FormValue("error"); e != "" {
+		writeError(w, http.StatusBadRequest, p.Name()+" auth failed: "+e)
+		return
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "missing code")
+		return
+	}
+
+	ident, err := p.Exchange(r.Context(), code, st.Verifier, st.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", p.Name()).Msg("oauth exchange failed")
+		writeError(w, http.StatusUnauthorized, "failed to verify identity")
+		return
+	}
+
+	// Привязка дополнительного способа входа к уже залогиненному пользователю
+	if st.LinkUserID != 0 {
+		completeLink(w, r, st.LinkUserID, ident, st.ReturnTo)
+		return
+	}
+
+	completeLogin(w, r, ident, st.ReturnTo)
+}
+
+// ==== Вариант, когда фронт уже сделал OAuth у провайдера и прислал id_token ====
+
+type frontendAuthRequest struct {
+	IDToken string `json:"id_token"`
+	Nonce   string `json:"nonce,omitempty"` // если фронт передавал nonce провайдеру, сверим его
+}
+
+// ProviderFrontendAuthHandler принимает id_token с фронта, проверяет его по ключам провайдера
+// и выдаёт СВОЙ JWT, который вы дальше используете в Authorization: Bearer ...
+// Доступен только для OIDC-провайдеров.
+func ProviderFrontendAuthHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	verifier, ok := p.(identity.IDTokenVerifier)
+	if !ok {
+		writeError(w, http.StatusNotFound, "provider does not support id_token login")
+		return
+	}
+
+	var req frontendAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IDToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid id_token")
+		return
+	}
+
+	ident, err := verifier.VerifyIDToken(r.Context(), req.IDToken, req.Nonce)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid "+p.Name()+" token: "+err.Error())
+		return
+	}
+
+	completeLogin(w, r, ident, "")
+}
+
+// authResponse — ответ на успешный логин любым способом
+type authResponse struct {
+	tokenResponse          // наши access JWT и refresh-токен
+	User          authUser `json:"user"`
+}
+
+// Данные пользователя, которые мы отдаём фронту после логина
+type authUser struct {
+	Provider string `json:"provider"`
+	Sub      string `json:"sub"` // ID пользователя у провайдера
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Picture  string `json:"picture"`
+}
+
+// completeLogin — общий финал любого входа: находит/создаёт пользователя по способу входа,
+// требует второй фактор, если он включён, иначе завершает вход через finishLogin.
+func completeLogin(w http.ResponseWriter, r *http.Request, ident *identity.Identity, returnTo string) {
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	if ident == nil || ident.Subject == "" {
+		log.Error().Msg("identity provider returned empty subject")
+		writeError(w, http.StatusUnauthorized, "failed to verify identity")
+		return
+	}
+
+	dbUser, isNew, err := db.GetOrCreateUserByIdentity(
+		r.Context(),
+		pool,
+		ident.Provider,
+		ident.Subject,
+		ident.Email,
+		ident.Name,
+		ident.Picture,
+	)
+	if err != nil {
+		log.Error().Err(err).Str("provider", ident.Provider).Msg("failed to get or create user")
+		writeError(w, http.StatusInternalServerError, "failed to process user")
+		return
+	}
+
+	if isNew {
+		log.Info().Int64("user_id", dbUser.ID).Str("provider", ident.Provider).Str("email", dbUser.Email).Msg("new user registered")
+	}
+
+	// Пользователям с включённым вторым фактором вместо токенов выдаём mfa_token
+	mfa, err := db.GetUserMFA(r.Context(), pool, dbUser.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", dbUser.ID).Msg("failed to get user mfa")
+		writeError(w, http.StatusInternalServerError, "failed to process user")
+		return
+	}
+	if mfa.Enabled() {
+		requireMFA(w, r, dbUser.ID, ident, returnTo)
+		return
+	}
+
+	finishLogin(w, r, dbUser, ident.Provider, ident.Subject, returnTo)
+}
+
+// finishLogin регистрирует сессию устройства, выдаёт СВОИ access JWT и refresh-токен
+// и отдаёт их клиенту (JSON или редирект на return_to).
+func finishLogin(w http.ResponseWriter, r *http.Request, dbUser *models.User, provider, subject, returnTo string) {
+	tokens, err := startSession(r, dbUser)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", dbUser.ID).Msg("failed to issue tokens")
+		writeError(w, http.StatusInternalServerError, "failed to issue tokens")
+		return
+	}
+
+	log.Info().Int64("user_id", dbUser.ID).Str("provider", provider).Str("email", dbUser.Email).Msg("user logged in")
+
+	// Если логин начинался со страницы фронтенда — возвращаем пользователя туда
+	if returnTo != "" {
+		redirectWithTokens(w, r, returnTo, tokens)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, authResponse{
+		tokenResponse: *tokens,
+		User: authUser{
+			Provider: provider,
+			Sub:      subject,
+			Email:    dbUser.Email,
+			Name:     dbUser.Name,
+			Picture:  dbUser.Picture,
+		},
+	})
+}
@@ -0,0 +1,178 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler обменивает refresh-токен на новую пару токенов (ротация).
+// Повторное предъявление уже обменянного токена считается утечкой:
+// всё семейство токенов и его сессия отзываются, и клиенту придётся логиниться заново.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid refresh_token")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	old, err := db.GetRefreshTokenByHash(r.Context(), pool, hashToken(req.RefreshToken))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get refresh token")
+		writeError(w, http.StatusInternalServerError, "failed to refresh token")
+		return
+	}
+	if old == nil || old.RevokedAt != nil {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	if old.ExpiresAt.Before(time.Now()) {
+		writeError(w, http.StatusUnauthorized, "refresh token expired")
+		return
+	}
+
+	// Токены без сессии выдавались до появления реестра устройств — такие больше не продлеваем
+	if old.SessionID == nil {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+
+	session, err := db.GetSessionByID(r.Context(), pool, *old.SessionID)
+	if err != nil {
+		log.Error().Err(err).Int64("session_id", *old.SessionID).Msg("failed to load session for refresh")
+		writeError(w, http.StatusInternalServerError, "failed to refresh token")
+		return
+	}
+	if session == nil || session.RevokedAt != nil {
+		writeError(w, http.StatusUnauthorized, "session revoked")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), pool, old.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", old.UserID).Msg("failed to load user for refresh")
+		writeError(w, http.StatusInternalServerError, "failed to refresh token")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "user not found")
+		return
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to refresh token")
+		return
+	}
+
+	if _, err := db.RotateRefreshToken(r.Context(), pool, old, hashToken(refresh), time.Now().Add(refreshTokenTTL)); err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			log.Warn().Int64("user_id", old.UserID).Int64("session_id", session.ID).Msg("refresh token reuse detected, session revoked")
+			closeSessionSockets(session.ID)
+			writeError(w, http.StatusUnauthorized, "refresh token reused")
+			return
+		}
+		log.Error().Err(err).Msg("failed to rotate refresh token")
+		writeError(w, http.StatusInternalServerError, "failed to refresh token")
+		return
+	}
+
+	if err := db.TouchSession(r.Context(), pool, session.ID, clientIP(r)); err != nil {
+		log.Warn().Err(err).Int64("session_id", session.ID).Msg("failed to touch session")
+	}
+
+	access, err := issueAccessToken(user, session.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to sign jwt")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	})
+}
+
+// LogoutHandler отзывает сессию переданного refresh-токена (выход с текущего устройства).
+// Отвечает 204 даже для неизвестного токена, чтобы не раскрывать его существование.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid refresh_token")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	t, err := db.GetRefreshTokenByHash(r.Context(), pool, hashToken(req.RefreshToken))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get refresh token")
+		writeError(w, http.StatusInternalServerError, "failed to logout")
+		return
+	}
+
+	if t != nil {
+		if t.SessionID != nil {
+			_, err = db.RevokeSession(r.Context(), pool, t.UserID, *t.SessionID)
+		} else {
+			err = db.RevokeRefreshTokenFamily(r.Context(), pool, t.FamilyID)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to revoke session")
+			writeError(w, http.StatusInternalServerError, "failed to logout")
+			return
+		}
+		if t.SessionID != nil {
+			closeSessionSockets(*t.SessionID)
+		}
+		log.Info().Int64("user_id", t.UserID).Msg("user logged out")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler отзывает все сессии текущего пользователя (выход со всех устройств)
+// и закрывает их открытые сокеты.
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	sessionIDs, err := db.RevokeUserSessions(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to revoke user sessions")
+		writeError(w, http.StatusInternalServerError, "failed to logout")
+		return
+	}
+
+	for _, id := range sessionIDs {
+		closeSessionSockets(id)
+	}
+
+	log.Info().Int64("user_id", user.ID).Msg("user logged out from all devices")
+	w.WriteHeader(http.StatusNoContent)
+}
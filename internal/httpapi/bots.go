@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+)
+
+type createBotRequest struct {
+	Name    string `json:"name"`
+	Picture string `json:"picture,omitempty"`
+}
+
+// CreateBotHandler создаёт бот-аккаунт текущего пользователя.
+// Войти ботом можно только персональным токеном (POST /api/tokens с bot_id).
+func CreateBotHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req createBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	bot, err := db.CreateBot(r.Context(), pool, user.ID, truncate(req.Name, 255), req.Picture)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to create bot")
+		writeError(w, http.StatusInternalServerError, "failed to create bot")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("bot_id", bot.ID).Msg("bot created")
+	writeJSON(w, http.StatusCreated, bot)
+}
+
+// ListBotsHandler отдаёт ботов текущего пользователя
+func ListBotsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	bots, err := db.ListBots(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list bots")
+		writeError(w, http.StatusInternalServerError, "failed to list bots")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bots)
+}
+
+// DeleteBotHandler удаляет бота; его токены перестают работать сразу
+func DeleteBotHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	botID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid bot id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.DeleteBot(r.Context(), pool, user.ID, botID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("bot_id", botID).Msg("failed to delete bot")
+		writeError(w, http.StatusInternalServerError, "failed to delete bot")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "bot not found")
+		return
+	}
+
+	wsHub.CloseUser(botID, hub.CloseUnauthorized, "bot deleted")
+
+	log.Info().Int64("user_id", user.ID).Int64("bot_id", botID).Msg("bot deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
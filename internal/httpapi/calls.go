@@ -3,39 +3,84 @@ package httpapi
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/protocol"
 )
 
 // Простейший сигнальный слой для WebRTC.
-// Клиенты обмениваются offer/answer/ICE через этот backend (обычно по userID/roomID).
+// Клиенты обмениваются offer/answer/ICE через этот backend: сигнал доставляется
+// в открытые сокеты собеседника событием call.* (см. protocol.CallSignal).
 
-type CallSignal struct {
-	FromUserID int64           `json:"from_user_id"`
-	ToUserID   int64           `json:"to_user_id"`
-	Payload    json.RawMessage `json:"payload"` // SDP или ICE candidate
-}
+type CallSignal = protocol.CallSignal
 
 func CallOfferHandler(w http.ResponseWriter, r *http.Request) {
-	handleSignal(w, r)
+	handleSignal(w, r, protocol.TypeCallOffer)
 }
 
 func CallAnswerHandler(w http.ResponseWriter, r *http.Request) {
-	handleSignal(w, r)
+	handleSignal(w, r, protocol.TypeCallAnswer)
 }
 
 func CallCandidateHandler(w http.ResponseWriter, r *http.Request) {
-	handleSignal(w, r)
+	handleSignal(w, r, protocol.TypeCallCandidate)
 }
 
-// Заглушка: просто принимает JSON и отдаёт 202.
-// В реальном проекте тут логика доставки сигнала второму участнику (через WS/очередь/room manager).
-func handleSignal(w http.ResponseWriter, r *http.Request) {
+func CallHangupHandler(w http.ResponseWriter, r *http.Request) {
+	handleSignal(w, r, protocol.TypeCallHangup)
+}
+
+func handleSignal(w http.ResponseWriter, r *http.Request, typ string) {
 	var sig CallSignal
 	if err := json.NewDecoder(r.Body).Decode(&sig); err != nil {
-		http.Error(w, "invalid body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if err := sig.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Отправителя определяем по токену, а не доверяем телу запроса
+	sig.FromUserID = CurrentUser(r.Context()).ID
+
+	if apiErr := relaySignal(typ, sig, nil); apiErr != nil {
+		writeError(w, apiErr.status, apiErr.msg)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// handleWSCallSignal — кадры call.*: пересылаем собеседнику
+func handleWSCallSignal(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var sig CallSignal
+	if apiErr := decodeWSPayload(env, &sig); apiErr != nil {
+		return apiErr
+	}
+	sig.FromUserID = auth.user.ID
+
+	return relaySignal(env.Type, sig, c)
+}
+
+// relaySignal доставляет сигнал во все сокеты получателя (и в остальные сокеты
+// звонящего, чтобы другие его устройства знали о звонке). Получатель не в сети — 409.
+func relaySignal(typ string, sig CallSignal, origin *hub.Client) *apiError {
+	if sig.ToUserID == sig.FromUserID {
+		return &apiError{http.StatusBadRequest, "invalid to_user_id"}
+	}
+	if !wsHub.Online(sig.ToUserID) {
+		return &apiError{http.StatusConflict, "user is offline"}
+	}
 
+	env := newEvent(typ, "", sig)
+	if env == nil {
+		return &apiError{http.StatusInternalServerError, "failed to send signal"}
+	}
+	broadcast([]int64{sig.ToUserID, sig.FromUserID}, env, origin)
+
+	log.Debug().Str("type", typ).Int64("user_id", sig.FromUserID).Int64("to_user_id", sig.ToUserID).Msg("call signal relayed")
+	return nil
+}
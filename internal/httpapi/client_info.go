@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Доверять ли X-Forwarded-For / X-Real-IP (включать только за своим reverse proxy)
+var trustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+
+// clientIP определяет IP клиента для журнала сессий
+func clientIP(r *http.Request) string {
+	if trustProxyHeaders {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return truncate(strings.TrimSpace(first), 64)
+		}
+		if xr := r.Header.Get("X-Real-IP"); xr != "" {
+			return truncate(strings.TrimSpace(xr), 64)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return truncate(r.RemoteAddr, 64)
+	}
+	return host
+}
+
+// clientName — человекочитаемое имя клиента, которое приложение передаёт в X-Client-Name
+func clientName(r *http.Request) string {
+	return truncate(strings.TrimSpace(r.Header.Get("X-Client-Name")), 255)
+}
+
+// truncate обрезает строку до n байт, не разрывая UTF-8 символы
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
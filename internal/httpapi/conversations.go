@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// conversationListResponse — страница списка чатов; next_cursor передаётся в ?before=
+type conversationListResponse struct {
+	Conversations []models.ConversationSummary `json:"conversations"`
+	NextCursor    string                       `json:"next_cursor,omitempty"`
+}
+
+// ListConversationsHandler отдаёт переписки текущего пользователя по убыванию последней активности
+func ListConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	limit, ok := pageLimit(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	before, err := decodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	list, err := db.ListConversations(r.Context(), pool, user.ID, before, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list conversations")
+		writeError(w, http.StatusInternalServerError, "failed to list conversations")
+		return
+	}
+
+	resp := conversationListResponse{Conversations: list}
+	if len(list) == limit {
+		last := list[len(list)-1]
+		activity := last.CreatedAt
+		if last.LastMessageAt != nil {
+			activity = *last.LastMessageAt
+		}
+		resp.NextCursor = encodeCursor(activity, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// messageListResponse — страница истории в хронологическом порядке.
+// prev_cursor передаётся в ?before= (сообщения старше), next_cursor — в ?after= (новее).
+type messageListResponse struct {
+	Messages   []models.Message `json:"messages"`
+	PrevCursor string           `json:"prev_cursor,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	HasMore    bool             `json:"has_more"` // есть ещё сообщения в направлении запроса
+}
+
+// ListMessagesHandler отдаёт историю переписки: ?before= — листать назад,
+// ?after= — догрузить новые, без курсоров — последние сообщения.
+func ListMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	limit, ok := pageLimit(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	before, err := decodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	after, err := decodeCursor(r.URL.Query().Get("after"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	member, err := db.IsConversationMember(r.Context(), pool, conversationID, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("conversation_id", conversationID).Msg("failed to check conversation member")
+		writeError(w, http.StatusInternalServerError, "failed to list messages")
+		return
+	}
+	if !member {
+		writeError(w, http.StatusNotFound, "conversation not found")
+		return
+	}
+
+	msgs, hasMore, err := db.ListMessages(r.Context(), pool, conversationID, user.ID, before, after, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("conversation_id", conversationID).Msg("failed to list messages")
+		writeError(w, http.StatusInternalServerError, "failed to list messages")
+		return
+	}
+
+	resp := messageListResponse{Messages: msgs, HasMore: hasMore}
+	if len(msgs) > 0 {
+		first, last := msgs[0], msgs[len(msgs)-1]
+		resp.PrevCursor = encodeCursor(first.CreatedAt, first.ID)
+		resp.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	} else if after != nil {
+		// Новых пока нет — клиент продолжает опрашивать с тем же курсором
+		resp.NextCursor = r.URL.Query().Get("after")
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yeoboseyo/server/internal/db"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor упаковывает позицию keyset-пагинации в непрозрачную строку.
+// Время — в микросекундах: точность timestamptz в Postgres.
+func encodeCursor(t time.Time, id int64) string {
+	raw := strconv.FormatInt(t.UnixMicro(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor разбирает курсор из encodeCursor; пустая строка — курсора нет
+func decodeCursor(s string) (*db.Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	cursorID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &db.Cursor{Time: time.UnixMicro(micros), ID: cursorID}, nil
+}
+
+// pageLimit читает ?limit= (по умолчанию defaultPageLimit, не больше maxPageLimit)
+func pageLimit(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultPageLimit, true
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	return min(n, maxPageLimit), true
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 17, 12, 30, 45, 123456789, time.UTC)
+
+	c, err := decodeCursor(encodeCursor(at, 42))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	// Postgres хранит микросекунды — наносекунды курсор отбрасывает
+	if !c.Time.Equal(at.Truncate(time.Microsecond)) || c.ID != 42 {
+		t.Fatalf("unexpected cursor %v/%d", c.Time, c.ID)
+	}
+
+	// Время до эпохи и нулевой id тоже переживают упаковку
+	old := time.Date(1960, 1, 1, 0, 0, 0, 1000, time.UTC)
+	c, err = decodeCursor(encodeCursor(old, 0))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !c.Time.Equal(old) || c.ID != 0 {
+		t.Fatalf("unexpected cursor %v/%d", c.Time, c.ID)
+	}
+}
+
+func TestCursorSameMicrosecondOrdersByID(t *testing.T) {
+	at := time.Date(2026, 10, 17, 12, 0, 0, 5000, time.UTC)
+
+	a, _ := decodeCursor(encodeCursor(at, 1))
+	b, _ := decodeCursor(encodeCursor(at.Add(999), 2)) // та же микросекунда
+	if !a.Time.Equal(b.Time) {
+		t.Fatalf("expected equal times, got %v and %v", a.Time, b.Time)
+	}
+	if a.ID == b.ID {
+		t.Fatal("cursors of different messages must differ by id")
+	}
+}
+
+func TestDecodeCursorEmpty(t *testing.T) {
+	c, err := decodeCursor("")
+	if err != nil || c != nil {
+		t.Fatalf("empty cursor must mean no cursor, got %v, %v", c, err)
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	cases := map[string]string{
+		"not base64":      "%%%",
+		"padded base64":   base64.URLEncoding.EncodeToString([]byte("1:23")),
+		"std alphabet":    base64.RawStdEncoding.EncodeToString([]byte{0xfb, 0xff}),
+		"no separator":    enc("12345"),
+		"json payload":    enc(`{"t":1,"id":2}`),
+		"bad time":        enc("abc:1"),
+		"bad id":          enc("1:abc"),
+		"empty parts":     enc(":"),
+		"id overflow":     enc("1:99999999999999999999"),
+		"extra separator": enc("1:2:3"),
+	}
+	for name, raw := range cases {
+		if _, err := decodeCursor(raw); err != errInvalidCursor {
+			t.Errorf("%s: expected errInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	cases := []struct {
+		query string
+		limit int
+		ok    bool
+	}{
+		{"", defaultPageLimit, true},
+		{"limit=10", 10, true},
+		{"limit=1000", maxPageLimit, true},
+		{"limit=0", 0, false},
+		{"limit=-1", 0, false},
+		{"limit=ten", 0, false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/api/conversations?"+c.query, nil)
+		limit, ok := pageLimit(r)
+		if limit != c.limit || ok != c.ok {
+			t.Errorf("%q: got %d, %v; want %d, %v", c.query, limit, ok, c.limit, c.ok)
+		}
+	}
+}
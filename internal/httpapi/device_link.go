@@ -0,0 +1,273 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+)
+
+// Провайдер в логах для входа по QR-коду
+const deviceLinkProvider = "device_link"
+
+var (
+	// Сколько живёт QR-код: новое устройство должно перезапросить его по истечении
+	deviceLinkTTL = envDuration("DEVICE_LINK_TTL", 2*time.Minute)
+	// Сколько держим long-poll /auth/link/wait открытым
+	deviceLinkPollTimeout = 25 * time.Second
+	// Как часто ожидание перепроверяет БД (подтверждение могло прийти на другой инстанс)
+	deviceLinkRecheck = 2 * time.Second
+
+	deviceLinkStartPerIP   = newRateLimiter(60, time.Hour)
+	deviceLinkApprovePerIP = newRateLimiter(30, 15*time.Minute)
+)
+
+// deviceLinkStartResponse — code кодируется в QR, wait_token устройство держит у себя
+type deviceLinkStartResponse struct {
+	Code      string `json:"code"`
+	WaitToken string `json:"wait_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// DeviceLinkStartHandler начинает вход по QR-коду на новом устройстве.
+// Код из ответа показывается в QR; по wait_token устройство ждёт подтверждения
+// через POST /auth/link/wait.
+func DeviceLinkStartHandler(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if !deviceLinkStartPerIP.Allow(ip) {
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	code, err := randomToken(16)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start linking")
+		return
+	}
+	waitToken, err := randomToken(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start linking")
+		return
+	}
+
+	_, err = db.CreateDeviceLink(
+		r.Context(),
+		pool,
+		hashToken(code),
+		hashToken(waitToken),
+		clientName(r),
+		truncate(r.UserAgent(), 1024),
+		ip,
+		time.Now().Add(deviceLinkTTL),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create device link")
+		writeError(w, http.StatusInternalServerError, "failed to start linking")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deviceLinkStartResponse{
+		Code:      code,
+		WaitToken: waitToken,
+		ExpiresIn: int64(deviceLinkTTL.Seconds()),
+	})
+}
+
+type deviceLinkWaitRequest struct {
+	WaitToken string `json:"wait_token"`
+}
+
+// DeviceLinkWaitHandler — long-poll нового устройства. Как только вход подтверждён,
+// отдаёт токены новой сессии (тот же ответ, что и при обычном входе). Если за
+// время ожидания подтверждения не было — 202 {"status": "pending"}, нужно повторить запрос.
+func DeviceLinkWaitHandler(w http.ResponseWriter, r *http.Request) {
+	var req deviceLinkWaitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WaitToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid wait_token")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	link, err := db.GetDeviceLinkByWaitToken(r.Context(), pool, hashToken(req.WaitToken))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get device link")
+		writeError(w, http.StatusInternalServerError, "failed to check link")
+		return
+	}
+	if link == nil {
+		writeError(w, http.StatusNotFound, "link not found")
+		return
+	}
+
+	approved, unsubscribe := deviceLinkWaiters.subscribe(link.ID)
+	defer unsubscribe()
+
+	deadline := time.NewTimer(min(deviceLinkPollTimeout, time.Until(link.ExpiresAt)))
+	defer deadline.Stop()
+	recheck := time.NewTicker(deviceLinkRecheck)
+	defer recheck.Stop()
+
+	for {
+		switch {
+		case link.ConsumedAt != nil:
+			writeError(w, http.StatusGone, "link already used")
+			return
+		case link.ApprovedAt != nil:
+			completeDeviceLink(w, r, link.ID)
+			return
+		case time.Now().After(link.ExpiresAt):
+			writeError(w, http.StatusGone, "link expired")
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			writeJSON(w, http.StatusAccepted, map[string]any{
+				"status":     "pending",
+				"expires_in": int64(time.Until(link.ExpiresAt).Seconds()),
+			})
+			return
+		case <-approved:
+		case <-recheck.C:
+		}
+
+		link, err = db.GetDeviceLinkByWaitToken(r.Context(), pool, hashToken(req.WaitToken))
+		if err != nil || link == nil {
+			log.Error().Err(err).Msg("failed to get device link")
+			writeError(w, http.StatusInternalServerError, "failed to check link")
+			return
+		}
+	}
+}
+
+// completeDeviceLink погашает подтверждённый вход и заводит сессию для нового устройства
+func completeDeviceLink(w http.ResponseWriter, r *http.Request, linkID int64) {
+	pool := DB()
+
+	userID, err := db.ConsumeDeviceLink(r.Context(), pool, linkID)
+	if err != nil {
+		log.Error().Err(err).Int64("link_id", linkID).Msg("failed to consume device link")
+		writeError(w, http.StatusInternalServerError, "failed to complete linking")
+		return
+	}
+	if userID == 0 {
+		// Параллельный запрос с тем же wait_token успел забрать вход
+		writeError(w, http.StatusGone, "link already used")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), pool, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("failed to load user")
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusGone, "user not found")
+		return
+	}
+
+	finishLogin(w, r, user, deviceLinkProvider, strconv.FormatInt(linkID, 10), "")
+}
+
+type deviceLinkApproveRequest struct {
+	Code string `json:"code"`
+}
+
+// DeviceLinkApproveHandler подтверждает вход нового устройства по коду из QR.
+// Отвечает сведениями об устройстве, чтобы клиент мог показать, кого впустили.
+func DeviceLinkApproveHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req deviceLinkApproveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+
+	if !deviceLinkApprovePerIP.Allow(clientIP(r)) {
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	link, err := db.ApproveDeviceLink(r.Context(), pool, hashToken(req.Code), user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to approve device link")
+		writeError(w, http.StatusInternalServerError, "failed to approve link")
+		return
+	}
+	if link == nil {
+		writeError(w, http.StatusNotFound, "link not found or expired")
+		return
+	}
+
+	deviceLinkWaiters.notify(link.ID)
+
+	log.Info().Int64("user_id", user.ID).Int64("link_id", link.ID).Str("ip", link.IP).Msg("device link approved")
+	writeJSON(w, http.StatusOK, link)
+}
+
+// linkWaiters будит long-poll'ы, ждущие подтверждения, сразу после него
+// (без этого ожидание заметит подтверждение только при очередной проверке БД).
+type linkWaiters struct {
+	mu    sync.Mutex
+	chans map[int64]map[chan struct{}]struct{}
+}
+
+var deviceLinkWaiters = &linkWaiters{chans: make(map[int64]map[chan struct{}]struct{})}
+
+func (l *linkWaiters) subscribe(linkID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	if l.chans[linkID] == nil {
+		l.chans[linkID] = make(map[chan struct{}]struct{})
+	}
+	l.chans[linkID][ch] = struct{}{}
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.chans[linkID], ch)
+		if len(l.chans[linkID]) == 0 {
+			delete(l.chans, linkID)
+		}
+	}
+}
+
+func (l *linkWaiters) notify(linkID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.chans[linkID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
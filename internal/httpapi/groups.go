@@ -0,0 +1,418 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Максимум участников группы (вместе с владельцем)
+var groupMaxMembers = int(envInt("GROUP_MAX_MEMBERS", 256))
+
+// Предельная длина ссылки на аватар группы
+const maxAvatarURLLength = 2048
+
+// validAvatarURL проверяет, что аватар — абсолютная http(s)-ссылка разумной длины:
+// его получают все участники, и клиенты подставляют его как есть
+func validAvatarURL(raw string) bool {
+	if len(raw) > maxAvatarURLLength {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return false
+	}
+	return u.Scheme == "https" || u.Scheme == "http"
+}
+
+type createGroupRequest struct {
+	Title     string  `json:"title"`
+	Avatar    *string `json:"avatar,omitempty"`
+	MemberIDs []int64 `json:"member_ids,omitempty"`
+}
+
+// CreateGroupHandler создаёт группу, где текущий пользователь — владелец
+func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req createGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		writeError(w, http.StatusBadRequest, "title is required")
+		return
+	}
+	if req.Avatar != nil && *req.Avatar == "" {
+		req.Avatar = nil
+	}
+	if req.Avatar != nil && !validAvatarURL(*req.Avatar) {
+		writeError(w, http.StatusBadRequest, "avatar must be an http(s) URL")
+		return
+	}
+	req.MemberIDs = slices.Compact(slices.Sorted(slices.Values(req.MemberIDs)))
+	if len(req.MemberIDs)+1 > groupMaxMembers {
+		writeError(w, http.StatusConflict, "too many members")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	group, posted, err := db.CreateGroup(r.Context(), pool, user.ID, truncate(req.Title, 255), req.Avatar, req.MemberIDs, groupMaxMembers)
+	if err != nil {
+		writeGroupError(w, err, user.ID, 0, "failed to create group")
+		return
+	}
+
+	broadcastPosted(posted)
+
+	log.Info().Int64("user_id", user.ID).Int64("conversation_id", group.ID).Msg("group created")
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// ListMembersHandler отдаёт участников переписки с ролями
+func ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	member, err := db.IsConversationMember(r.Context(), pool, conversationID, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("conversation_id", conversationID).Msg("failed to check conversation member")
+		writeError(w, http.StatusInternalServerError, "failed to list members")
+		return
+	}
+	if !member {
+		writeError(w, http.StatusNotFound, "conversation not found")
+		return
+	}
+
+	members, err := db.ListConversationMembers(r.Context(), pool, conversationID)
+	if err != nil {
+		log.Error().Err(err).Int64("conversation_id", conversationID).Msg("failed to list conversation members")
+		writeError(w, http.StatusInternalServerError, "failed to list members")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, members)
+}
+
+type updateGroupRequest struct {
+	Title  *string `json:"title,omitempty"`
+	Avatar *string `json:"avatar,omitempty"` // "" — убрать аватар
+}
+
+// UpdateGroupHandler меняет название и аватар группы (admin и owner)
+func UpdateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	var req updateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.Title != nil {
+		title := truncate(strings.TrimSpace(*req.Title), 255)
+		if title == "" {
+			writeError(w, http.StatusBadRequest, "title cannot be empty")
+			return
+		}
+		req.Title = &title
+	}
+	if req.Avatar != nil && *req.Avatar != "" && !validAvatarURL(*req.Avatar) {
+		writeError(w, http.StatusBadRequest, "avatar must be an http(s) URL")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	group, posted, err := db.UpdateGroup(r.Context(), pool, conversationID, user.ID, req.Title, req.Avatar)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to update group")
+		return
+	}
+
+	broadcastPosted(posted)
+	writeJSON(w, http.StatusOK, group)
+}
+
+type addMembersRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// AddMembersHandler добавляет участников в группу (admin и owner)
+func AddMembersHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	var req addMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if len(req.UserIDs) == 0 {
+		writeError(w, http.StatusBadRequest, "user_ids is required")
+		return
+	}
+	if len(req.UserIDs) > groupMaxMembers {
+		writeError(w, http.StatusConflict, "too many members")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	posted, err := db.AddGroupMembers(r.Context(), pool, conversationID, user.ID, req.UserIDs, groupMaxMembers)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to add members")
+		return
+	}
+
+	broadcastPosted(posted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMemberHandler исключает участника из группы: admin — обычных участников,
+// owner — любых. Свой id — то же, что выйти из группы.
+func RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+	memberID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var posted []db.PostedMessage
+	if memberID == user.ID {
+		posted, err = db.LeaveGroup(r.Context(), pool, conversationID, user.ID)
+	} else {
+		posted, err = db.RemoveGroupMember(r.Context(), pool, conversationID, user.ID, memberID)
+	}
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to remove member")
+		return
+	}
+
+	broadcastPosted(posted)
+	typingTracker.Stop(conversationID, memberID)
+
+	log.Info().Int64("user_id", user.ID).Int64("conversation_id", conversationID).Int64("member_id", memberID).Msg("group member removed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LeaveGroupHandler выводит текущего пользователя из группы
+func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	posted, err := db.LeaveGroup(r.Context(), pool, conversationID, user.ID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to leave group")
+		return
+	}
+
+	broadcastPosted(posted)
+	typingTracker.Stop(conversationID, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type setRoleRequest struct {
+	Role string `json:"role"` // admin, member или owner (передать владение)
+}
+
+// SetMemberRoleHandler назначает и снимает админов, передаёт владение (только owner)
+func SetMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+	memberID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req setRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if models.RoleRank(req.Role) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid role")
+		return
+	}
+	if memberID == user.ID {
+		writeError(w, http.StatusBadRequest, "cannot change own role")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	posted, err := db.SetGroupMemberRole(r.Context(), pool, conversationID, user.ID, memberID, req.Role)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to set role")
+		return
+	}
+
+	broadcastPosted(posted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type pinMessageRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// PinMessageHandler закрепляет сообщение группы (admin и owner)
+func PinMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var req pinMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.MessageID <= 0 {
+		writeError(w, http.StatusBadRequest, "message_id is required")
+		return
+	}
+
+	pinMessage(w, r, req.MessageID)
+}
+
+// UnpinMessageHandler снимает закреплённое сообщение группы (admin и owner)
+func UnpinMessageHandler(w http.ResponseWriter, r *http.Request) {
+	pinMessage(w, r, 0)
+}
+
+func pinMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	posted, err := db.PinGroupMessage(r.Context(), pool, conversationID, user.ID, messageID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to pin message")
+		return
+	}
+
+	broadcastPosted(posted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeGroupError отдаёт ошибку операции с группой; неожиданные ошибки логируются
+func writeGroupError(w http.ResponseWriter, err error, userID, conversationID int64, msg string) {
+	switch {
+	case errors.Is(err, db.ErrNotGroupMember):
+		writeError(w, http.StatusNotFound, "group or member not found")
+	case errors.Is(err, db.ErrGroupForbidden):
+		writeError(w, http.StatusForbidden, "not enough rights")
+	case errors.Is(err, db.ErrGroupFull):
+		writeError(w, http.StatusConflict, "group is full")
+	case errors.Is(err, db.ErrNoSuchMessage):
+		writeError(w, http.StatusNotFound, "message not found")
+	case errors.Is(err, db.ErrInviteNotFound):
+		writeError(w, http.StatusNotFound, "invite not found")
+	case errors.Is(err, db.ErrInviteNeedsApproval):
+		writeError(w, http.StatusForbidden, "invite requires admin approval, send a join request")
+	case errors.Is(err, db.ErrInviteNoApproval):
+		writeError(w, http.StatusConflict, "invite does not require approval, join directly")
+	case errors.Is(err, db.ErrInviteExhausted):
+		writeError(w, http.StatusConflict, "invite usage limit reached")
+	case errors.Is(err, db.ErrJoinRequestNotFound):
+		writeError(w, http.StatusNotFound, "join request not found")
+	default:
+		log.Error().Err(err).Int64("user_id", userID).Int64("conversation_id", conversationID).Msg(msg)
+		writeError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// broadcastPosted рассылает служебные сообщения группы участникам
+func broadcastPosted(posted []db.PostedMessage) {
+	for _, p := range posted {
+		broadcastMessage(p.Message, p.Seqs, nil)
+	}
+}
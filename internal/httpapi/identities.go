@@ -0,0 +1,347 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/identity"
+)
+
+const (
+	tokenTypeMerge = "merge"
+	// Сколько живёт подтверждение на слияние аккаунтов
+	mergeTokenTTL = 10 * time.Minute
+)
+
+// mergeClaims — подтверждение, что владелец основного аккаунта только что доказал
+// владение способом входа дубликата. Выдаётся при конфликте привязки.
+// Если у дубликата включён второй фактор, одного способа входа мало:
+// слияние дополнительно требует его код (MFARequired).
+type mergeClaims struct {
+	PrimaryUserID   int64  `json:"primary_user_id"`
+	SecondaryUserID int64  `json:"secondary_user_id"`
+	MFARequired     bool   `json:"mfa_required,omitempty"`
+	Type            string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// ListIdentitiesHandler отдаёт способы входа текущего пользователя
+func ListIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	identities, err := db.ListUserIdentities(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list identities")
+		writeError(w, http.StatusInternalServerError, "failed to list identities")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, identities)
+}
+
+type startLinkRequest struct {
+	ReturnTo string `json:"return_to,omitempty"`
+}
+
+// StartLinkIdentityHandler начинает привязку способа входа через redirect-флоу провайдера:
+// ставит куку со state (с пометкой link) и отдаёт URL, на который фронт должен перейти.
+func StartLinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	p, ok := providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req startLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.ReturnTo != "" && !validReturnTo(req.ReturnTo) {
+		writeError(w, http.StatusBadRequest, "return_to is not allowed")
+		return
+	}
+
+	st, err := newOAuthState(p.Name(), req.ReturnTo)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start linking")
+		return
+	}
+	st.LinkUserID = user.ID
+
+	authURL, err := p.AuthCodeURL(r.Context(), st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		log.Error().Err(err).Str("provider", p.Name()).Msg("failed to build auth url")
+		writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	if err := setOAuthStateCookie(w, p, st); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start linking")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"url": authURL})
+}
+
+// LinkIdentityTokenHandler привязывает способ входа по id_token, полученному фронтом у провайдера
+func LinkIdentityTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	p, ok := providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	verifier, ok := p.(identity.IDTokenVerifier)
+	if !ok {
+		writeError(w, http.StatusNotFound, "provider does not support id_token login")
+		return
+	}
+
+	var req frontendAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IDToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid id_token")
+		return
+	}
+
+	ident, err := verifier.VerifyIDToken(r.Context(), req.IDToken, req.Nonce)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid "+p.Name()+" token: "+err.Error())
+		return
+	}
+
+	completeLink(w, r, user.ID, ident, "")
+}
+
+// completeLink привязывает проверенный способ входа к пользователю userID.
+// Если способ уже принадлежит другому аккаунту, отвечает 409 и выдаёт merge_token,
+// которым можно подтвердить слияние аккаунтов через POST /api/account/merge.
+// mfa_required в ответе означает, что к слиянию нужен код второго фактора дубликата.
+func completeLink(w http.ResponseWriter, r *http.Request, userID int64, ident *identity.Identity, returnTo string) {
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	linked, created, err := db.LinkIdentity(r.Context(), pool, userID, ident.Provider, ident.Subject, ident.Email)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Str("provider", ident.Provider).Msg("failed to link identity")
+		writeError(w, http.StatusInternalServerError, "failed to link identity")
+		return
+	}
+
+	if linked.UserID != userID {
+		mfa, err := db.GetUserMFA(r.Context(), pool, linked.UserID)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", linked.UserID).Msg("failed to get user mfa")
+			writeError(w, http.StatusInternalServerError, "failed to link identity")
+			return
+		}
+
+		mergeToken, err := issueMergeToken(userID, linked.UserID, mfa.Enabled())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to link identity")
+			return
+		}
+
+		if returnTo != "" {
+			fragment := url.Values{
+				"error":       {"identity_in_use"},
+				"provider":    {ident.Provider},
+				"merge_token": {mergeToken},
+			}
+			if mfa.Enabled() {
+				fragment.Set("mfa_required", "true")
+			}
+			redirectWithFragment(w, r, returnTo, fragment)
+			return
+		}
+
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":        "identity is linked to another account",
+			"merge_token":  mergeToken,
+			"mfa_required": mfa.Enabled(),
+		})
+		return
+	}
+
+	if created {
+		log.Info().Int64("user_id", userID).Str("provider", ident.Provider).Msg("identity linked")
+	}
+
+	if returnTo != "" {
+		redirectWithFragment(w, r, returnTo, url.Values{"linked": {ident.Provider}})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, linked)
+}
+
+// UnlinkIdentityHandler отвязывает способ входа; последний отвязать нельзя
+func UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	identityID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid identity id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.UnlinkIdentity(r.Context(), pool, user.ID, identityID)
+	if err != nil {
+		if errors.Is(err, db.ErrLastIdentity) {
+			writeError(w, http.StatusConflict, "cannot unlink the last sign-in method")
+			return
+		}
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to unlink identity")
+		writeError(w, http.StatusInternalServerError, "failed to unlink identity")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "identity not found")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("identity_id", identityID).Msg("identity unlinked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type mergeRequest struct {
+	MergeToken   string `json:"merge_token"`
+	Code         string `json:"code,omitempty"`          // TOTP-код дубликата, если у него включён второй фактор
+	RecoveryCode string `json:"recovery_code,omitempty"` // или его код восстановления
+}
+
+// MergeAccountHandler сливает дублирующий аккаунт в текущий. Требует merge_token,
+// выданный при попытке привязать способ входа, который принадлежит дубликату,
+// а если у дубликата включён второй фактор — ещё и его код: иначе чужой способ входа
+// позволил бы забрать аккаунт, защищённый TOTP.
+func MergeAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req mergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MergeToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid merge_token")
+		return
+	}
+
+	claims, err := parseMergeToken(req.MergeToken)
+	if err != nil || claims.PrimaryUserID != user.ID {
+		writeError(w, http.StatusForbidden, "invalid merge_token")
+		return
+	}
+	if claims.MFARequired && req.Code == "" && req.RecoveryCode == "" {
+		writeError(w, http.StatusUnauthorized, "code or recovery_code of the merged account required")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	// Второй фактор мог быть включён уже после выдачи merge_token — проверяем по БД
+	mfa, err := db.GetUserMFA(r.Context(), pool, claims.SecondaryUserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", claims.SecondaryUserID).Msg("failed to get user mfa")
+		writeError(w, http.StatusInternalServerError, "failed to merge accounts")
+		return
+	}
+	if mfa.Enabled() {
+		ok, err := checkSecondFactor(r.Context(), claims.SecondaryUserID, req.Code, req.RecoveryCode)
+		if err != nil {
+			if errors.Is(err, errTooManyAttempts) {
+				writeError(w, http.StatusTooManyRequests, "too many attempts")
+				return
+			}
+			log.Error().Err(err).Int64("user_id", claims.SecondaryUserID).Msg("failed to verify second factor")
+			writeError(w, http.StatusInternalServerError, "failed to verify code")
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+	}
+
+	sessionIDs, err := db.MergeUsers(r.Context(), pool, user.ID, claims.SecondaryUserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("merged_user_id", claims.SecondaryUserID).Msg("failed to merge users")
+		writeError(w, http.StatusInternalServerError, "failed to merge accounts")
+		return
+	}
+
+	for _, id := range sessionIDs {
+		closeSessionSockets(id)
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("merged_user_id", claims.SecondaryUserID).Msg("accounts merged")
+
+	identities, err := db.ListUserIdentities(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list identities")
+		writeError(w, http.StatusInternalServerError, "failed to list identities")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user":       user,
+		"identities": identities,
+	})
+}
+
+func issueMergeToken(primaryID, secondaryID int64, mfaRequired bool) (string, error) {
+	claims := mergeClaims{
+		PrimaryUserID:   primaryID,
+		SecondaryUserID: secondaryID,
+		MFARequired:     mfaRequired,
+		Type:            tokenTypeMerge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mergeTokenTTL)),
+		},
+	}
+	return signJWT(claims)
+}
+
+func parseMergeToken(raw string) (*mergeClaims, error) {
+	claims := &mergeClaims{}
+	err := parseJWT(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != tokenTypeMerge || claims.PrimaryUserID == 0 || claims.SecondaryUserID == 0 {
+		return nil, errors.New("invalid merge token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+func mergeRequestAs(user *models.User, body string) *http.Request {
+	r := httptest.NewRequest("POST", "/api/account/merge", strings.NewReader(body))
+	auth := &authInfo{user: user}
+	return r.WithContext(auth.withContext(r.Context()))
+}
+
+// Слияние с дубликатом под вторым фактором без его кода отклоняется до обращения к БД
+func TestMergeAccountRequiresSecondaryMFA(t *testing.T) {
+	useTestKeys(t, "test-secret-test-secret-test-secret")
+
+	token, err := issueMergeToken(1, 2, true)
+	if err != nil {
+		t.Fatalf("issueMergeToken: %v", err)
+	}
+	claims, err := parseMergeToken(token)
+	if err != nil || !claims.MFARequired {
+		t.Fatalf("expected mfa_required in merge token, got %+v, %v", claims, err)
+	}
+
+	w := httptest.NewRecorder()
+	MergeAccountHandler(w, mergeRequestAs(&models.User{ID: 1}, `{"merge_token":"`+token+`"}`))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the second factor, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestMergeAccountRejectsForeignToken(t *testing.T) {
+	useTestKeys(t, "test-secret-test-secret-test-secret")
+
+	token, err := issueMergeToken(1, 2, false)
+	if err != nil {
+		t.Fatalf("issueMergeToken: %v", err)
+	}
+
+	// Токен выдан другому основному аккаунту
+	w := httptest.NewRecorder()
+	MergeAccountHandler(w, mergeRequestAs(&models.User{ID: 3}, `{"merge_token":"`+token+`"}`))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+}
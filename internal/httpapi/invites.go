@@ -0,0 +1,324 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Страница фронтенда, которая получает ?token= и показывает приглашение в группу
+var groupInviteURL = os.Getenv("GROUP_INVITE_URL")
+
+// inviteResponse — ссылка-приглашение и готовый адрес для отправки (если задан GROUP_INVITE_URL)
+type inviteResponse struct {
+	models.GroupInvite
+	URL string `json:"url,omitempty"`
+}
+
+func newInviteResponse(invite models.GroupInvite) inviteResponse {
+	resp := inviteResponse{GroupInvite: invite}
+	if groupInviteURL != "" {
+		sep := "?"
+		if strings.Contains(groupInviteURL, "?") {
+			sep = "&"
+		}
+		resp.URL = groupInviteURL + sep + "token=" + url.QueryEscape(invite.Token)
+	}
+	return resp
+}
+
+type createInviteRequest struct {
+	ExpiresIn        int64 `json:"expires_in,omitempty"` // в секундах; 0 — бессрочная
+	MaxUses          int   `json:"max_uses,omitempty"`   // 0 — без ограничения
+	RequiresApproval bool  `json:"requires_approval"`
+}
+
+// CreateInviteHandler создаёт ссылку-приглашение в группу (admin и owner)
+func CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	var req createInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	expiresAt, ok := expiresAtFrom(req.ExpiresIn)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid expires_in")
+		return
+	}
+	if req.MaxUses < 0 {
+		writeError(w, http.StatusBadRequest, "invalid max_uses")
+		return
+	}
+
+	var maxUses *int
+	if req.MaxUses > 0 {
+		maxUses = &req.MaxUses
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	token, err := randomToken(16)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create invite")
+		return
+	}
+
+	invite, err := db.CreateGroupInvite(r.Context(), pool, conversationID, user.ID, token, req.RequiresApproval, maxUses, expiresAt)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to create invite")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("conversation_id", conversationID).Int64("invite_id", invite.ID).Msg("group invite created")
+	writeJSON(w, http.StatusCreated, newInviteResponse(*invite))
+}
+
+// ListInvitesHandler отдаёт действующие ссылки группы (admin и owner)
+func ListInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	invites, err := db.ListGroupInvites(r.Context(), pool, conversationID, user.ID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to list invites")
+		return
+	}
+
+	resp := make([]inviteResponse, len(invites))
+	for i, invite := range invites {
+		resp[i] = newInviteResponse(invite)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeInviteHandler отзывает ссылку группы (admin и owner)
+func RevokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+	inviteID, err := strconv.ParseInt(mux.Vars(r)["inviteId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid invite id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.RevokeGroupInvite(r.Context(), pool, conversationID, user.ID, inviteID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to revoke invite")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "invite not found")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("conversation_id", conversationID).Int64("invite_id", inviteID).Msg("group invite revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InvitePreviewHandler показывает группу по ссылке, не вступая в неё
+func InvitePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	preview, err := db.GetGroupPreview(r.Context(), pool, mux.Vars(r)["token"], user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to get group preview")
+		writeError(w, http.StatusInternalServerError, "failed to get invite")
+		return
+	}
+	if preview == nil {
+		writeError(w, http.StatusNotFound, "invite not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// joinResponse — итог вступления по ссылке: member — пользователь в группе,
+// pending — заявка ждёт одобрения админом
+type joinResponse struct {
+	ConversationID int64  `json:"conversation_id"`
+	Status         string `json:"status"`
+}
+
+// JoinByInviteHandler вступает в группу по ссылке без одобрения
+func JoinByInviteHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	conversationID, posted, err := db.JoinGroupByInvite(r.Context(), pool, mux.Vars(r)["token"], user.ID, groupMaxMembers)
+	if err != nil {
+		writeGroupError(w, err, user.ID, 0, "failed to join group")
+		return
+	}
+
+	broadcastPosted(posted)
+	writeJSON(w, http.StatusOK, joinResponse{ConversationID: conversationID, Status: "member"})
+}
+
+// RequestToJoinHandler оставляет заявку на вступление по ссылке с одобрением
+func RequestToJoinHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	conversationID, member, err := db.RequestToJoinGroup(r.Context(), pool, mux.Vars(r)["token"], user.ID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, 0, "failed to request to join")
+		return
+	}
+	if member {
+		writeJSON(w, http.StatusOK, joinResponse{ConversationID: conversationID, Status: "member"})
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("conversation_id", conversationID).Msg("group join requested")
+	writeJSON(w, http.StatusAccepted, joinResponse{ConversationID: conversationID, Status: "pending"})
+}
+
+// ListJoinRequestsHandler отдаёт заявки на вступление в группу (admin и owner)
+func ListJoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	requests, err := db.ListJoinRequests(r.Context(), pool, conversationID, user.ID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to list join requests")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// ApproveJoinRequestHandler принимает заявку на вступление (admin и owner)
+func ApproveJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+	requesterID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	posted, err := db.ApproveJoinRequest(r.Context(), pool, conversationID, user.ID, requesterID, groupMaxMembers)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to approve join request")
+		return
+	}
+
+	broadcastPosted(posted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeclineJoinRequestHandler отклоняет заявку на вступление (admin и owner)
+func DeclineJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+	requesterID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.DeclineJoinRequest(r.Context(), pool, conversationID, user.ID, requesterID)
+	if err != nil {
+		writeGroupError(w, err, user.ID, conversationID, "failed to decline join request")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "join request not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
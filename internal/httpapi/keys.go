@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yeoboseyo/server/internal/jwtkeys"
+)
+
+// signingKeys — ключи для подписи и проверки наших JWT (access, state, merge, mfa)
+var signingKeys *jwtkeys.KeySet
+
+// SetKeySet задаёт ключи подписи; вызывается из main до запуска сервера
+func SetKeySet(ks *jwtkeys.KeySet) {
+	signingKeys = ks
+}
+
+var errNoSigningKeys = errors.New("signing keys not configured")
+
+// signJWT подписывает claims активным ключом (с kid в заголовке)
+func signJWT(claims jwt.Claims) (string, error) {
+	if signingKeys == nil {
+		return "", errNoSigningKeys
+	}
+	return signingKeys.Sign(claims)
+}
+
+// parseJWT проверяет подпись любым из действующих ключей и срок действия
+func parseJWT(raw string, claims jwt.Claims) error {
+	if signingKeys == nil {
+		return errNoSigningKeys
+	}
+	return signingKeys.Parse(raw, claims, jwt.WithExpirationRequired())
+}
+
+// JWKSHandler публикует публичные ключи, чтобы другие сервисы могли проверять наши токены
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if signingKeys == nil {
+		writeError(w, http.StatusInternalServerError, "signing keys not configured")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, signingKeys.JWKS())
+}
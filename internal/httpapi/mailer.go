@@ -0,0 +1,48 @@
+package httpapi
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/mail"
+)
+
+// mailSender — куда уходят письма (ссылки для входа и т.п.)
+var mailSender mail.Sender
+
+func init() {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	// MAIL_DRIVER: smtp — реальная отправка, file — .eml в MAIL_DIR, stdout (по умолчанию) — в лог
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil || port == 0 {
+			port = 587
+		}
+		mailSender = &mail.SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "file":
+		mailSender = &mail.FileSender{From: from, Dir: os.Getenv("MAIL_DIR")}
+	case "", "stdout":
+		mailSender = &mail.FileSender{From: from}
+	default:
+		log.Warn().Str("driver", driver).Msg("unknown MAIL_DRIVER, falling back to stdout")
+		mailSender = &mail.FileSender{From: from}
+	}
+}
+
+// SetMailSender подменяет отправщика писем (напр. в тестах)
+func SetMailSender(s mail.Sender) {
+	mailSender = s
+}
@@ -3,18 +3,53 @@ package httpapi
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
 )
 
-// В дальнейшем сюда вешается middleware, которая достаёт пользователя из токена/сессии.
+// MeHandler отдаёт текущего пользователя, которого положил в контекст AuthMiddleware.
 func MeHandler(w http.ResponseWriter, r *http.Request) {
-	// Заглушка: возвращаем фиктивного пользователя
-	user := map[string]any{
-		"id":    1,
-		"email": "user@example.com",
-		"name":  "Demo User",
+	user := CurrentUser(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(user)
+
+	writeJSON(w, http.StatusOK, user)
 }
 
+type updateSettingsRequest struct {
+	HideLastSeen *bool `json:"hide_last_seen,omitempty"`
+}
 
+// UpdateSettingsHandler меняет настройки приватности текущего пользователя
+func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req updateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.HideLastSeen == nil {
+		writeJSON(w, http.StatusOK, user)
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	updated, err := db.SetHideLastSeen(r.Context(), pool, user.ID, *req.HideLastSeen)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to update settings")
+		writeError(w, http.StatusInternalServerError, "failed to update settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,186 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+// Сколько после отправки автор может исправить сообщение или удалить его у всех
+var messageEditWindow = envDuration("MESSAGE_EDIT_WINDOW", 48*time.Hour)
+
+type editMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// EditMessageHandler меняет текст своего сообщения и рассылает message.edited участникам
+func EditMessageHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	var req editMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+	if utf8.RuneCountInString(req.Content) > maxMessageLength {
+		writeError(w, http.StatusBadRequest, "content is too long")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	msg, err := db.EditMessage(r.Context(), pool, messageID, user.ID, req.Content, messageEditWindow)
+	if err != nil {
+		writeMessageChangeError(w, err, user.ID, messageID, "failed to edit message")
+		return
+	}
+	if msg == nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	broadcastToMembers(r.Context(), msg.ConversationID, newEvent(protocol.TypeMessageEdited, "", msg))
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// DeleteMessageHandler удаляет сообщение: ?for=everyone (по умолчанию) — своё,
+// у всех участников; ?for=me — любое, только у себя
+func DeleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	deletedFor := r.URL.Query().Get("for")
+	if deletedFor == "" {
+		deletedFor = models.DeletedForEveryone
+	}
+	if deletedFor != models.DeletedForEveryone && deletedFor != models.DeletedForMe {
+		writeError(w, http.StatusBadRequest, "for must be everyone or me")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var msg *models.Message
+	if deletedFor == models.DeletedForEveryone {
+		msg, err = db.DeleteMessageForEveryone(r.Context(), pool, messageID, user.ID, messageEditWindow)
+	} else {
+		msg, err = db.DeleteMessageForMe(r.Context(), pool, messageID, user.ID)
+	}
+	if err != nil {
+		writeMessageChangeError(w, err, user.ID, messageID, "failed to delete message")
+		return
+	}
+	if msg == nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	event := newEvent(protocol.TypeMessageDeleted, "", protocol.MessageDeletedPayload{
+		ConversationID: msg.ConversationID,
+		MessageID:      msg.ID,
+		For:            deletedFor,
+		DeletedAt:      *msg.DeletedAt,
+	})
+	if deletedFor == models.DeletedForEveryone {
+		broadcastToMembers(r.Context(), msg.ConversationID, event)
+	} else {
+		broadcast([]int64{user.ID}, event, nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMessageEditsHandler отдаёт прежние версии сообщения
+func ListMessageEditsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	edits, ok, err := db.ListMessageEdits(r.Context(), pool, messageID, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("message_id", messageID).Msg("failed to list message edits")
+		writeError(w, http.StatusInternalServerError, "failed to list message edits")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, edits)
+}
+
+// writeMessageChangeError отдаёт ошибку правки/удаления; неожиданные ошибки логируются
+func writeMessageChangeError(w http.ResponseWriter, err error, userID, messageID int64, msg string) {
+	switch {
+	case errors.Is(err, db.ErrNotMessageAuthor):
+		writeError(w, http.StatusForbidden, "only the author can change the message")
+	case errors.Is(err, db.ErrMessageEditExpired):
+		writeError(w, http.StatusForbidden, "message can no longer be changed")
+	case errors.Is(err, db.ErrMessageDeleted):
+		writeError(w, http.StatusConflict, "message is deleted")
+	default:
+		log.Error().Err(err).Int64("user_id", userID).Int64("message_id", messageID).Msg(msg)
+		writeError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// broadcastToMembers рассылает событие во все сокеты участников переписки
+func broadcastToMembers(ctx context.Context, conversationID int64, env *protocol.Envelope) {
+	pool := DB()
+	if pool == nil {
+		return
+	}
+
+	members, err := db.ListConversationMemberIDs(ctx, pool, conversationID)
+	if err != nil {
+		log.Error().Err(err).Int64("conversation_id", conversationID).Msg("failed to list conversation members")
+		return
+	}
+	broadcast(members, env, nil)
+}
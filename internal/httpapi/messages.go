@@ -1,53 +1,160 @@
 package httpapi
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
-	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/models"
+	"github.com/yeoboseyo/server/internal/protocol"
 )
 
 type SendMessageRequest struct {
-	FromUserID int64  `json:"from_user_id"`
-	ToUserID   int64  `json:"to_user_id"`
-	Content    string `json:"content"`
+	FromUserID     int64  `json:"from_user_id"` // берётся из токена, значение из тела игнорируется
+	ToUserID       int64  `json:"to_user_id,omitempty"`
+	ConversationID int64  `json:"conversation_id,omitempty"` // вместо to_user_id: сообщение в группу или существующую переписку
+	Content        string `json:"content"`
 }
 
-// Заглушка хранения сообщений: на практике нужно писать в БД.
+// Максимальная длина сообщения в символах
+const maxMessageLength = 4096
+
+// SendMessageHandler сохраняет сообщение в личную переписку отправителя и получателя
+// (переписка создаётся при первом сообщении) или в переписку conversation_id,
+// доставляет его в открытые сокеты участников и отдаёт с id и временем сервера.
 func SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	var req SendMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid body")
 		return
 	}
 
-	// Здесь могли бы сохранить в БД и/или отправить через WebSocket онлайн-пользователю.
-	w.WriteHeader(http.StatusAccepted)
+	// Отправителя определяем по токену, а не доверяем телу запроса
+	req.FromUserID = CurrentUser(r.Context()).ID
+
+	msg, apiErr := sendMessage(r.Context(), req, nil)
+	if apiErr != nil {
+		writeError(w, apiErr.status, apiErr.msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// sendMessage проверяет и сохраняет сообщение и рассылает его в сокеты
+// участников. origin — соединение, с которого пришло сообщение
+// (nil для HTTP): ему событие не дублируется.
+func sendMessage(ctx context.Context, req SendMessageRequest, origin *hub.Client) (*models.Message, *apiError) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, &apiError{http.StatusBadRequest, "content is required"}
+	}
+	if utf8.RuneCountInString(req.Content) > maxMessageLength {
+		return nil, &apiError{http.StatusBadRequest, "content is too long"}
+	}
+
+	pool := DB()
+	if pool == nil {
+		return nil, &apiError{http.StatusInternalServerError, "database not initialized"}
+	}
+
+	var msg *models.Message
+	var seqs map[int64]int64
+	var apiErr *apiError
+	switch {
+	case req.ConversationID != 0 && req.ToUserID != 0:
+		return nil, &apiError{http.StatusBadRequest, "only one of to_user_id and conversation_id is allowed"}
+	case req.ConversationID != 0:
+		msg, seqs, apiErr = sendConversationMessage(ctx, pool, req)
+	default:
+		msg, seqs, apiErr = sendDirectMessage(ctx, pool, req)
+	}
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Индикатор набора гаснет у получателей по message.new, рассылать окончание не нужно
+	typingTracker.Stop(msg.ConversationID, msg.SenderID)
+	broadcastMessage(msg, seqs, origin)
+
+	// Отправителю — с его seq
+	own := *msg
+	own.Seq = seqs[msg.SenderID]
+	return &own, nil
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// sendDirectMessage сохраняет личное сообщение (переписка создаётся при первом сообщении)
+func sendDirectMessage(ctx context.Context, pool *pgxpool.Pool, req SendMessageRequest) (*models.Message, map[int64]int64, *apiError) {
+	if req.ToUserID <= 0 || req.ToUserID == req.FromUserID {
+		return nil, nil, &apiError{http.StatusBadRequest, "invalid to_user_id"}
+	}
+
+	recipient, err := db.GetUserByID(ctx, pool, req.ToUserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", req.ToUserID).Msg("failed to load recipient")
+		return nil, nil, &apiError{http.StatusInternalServerError, "failed to send message"}
+	}
+	if recipient == nil {
+		return nil, nil, &apiError{http.StatusNotFound, "recipient not found"}
+	}
+
+	msg, seqs, err := db.SendDirectMessage(ctx, pool, req.FromUserID, recipient.ID, req.Content)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", req.FromUserID).Int64("to_user_id", recipient.ID).Msg("failed to send message")
+		return nil, nil, &apiError{http.StatusInternalServerError, "failed to send message"}
+	}
+	return msg, seqs, nil
 }
 
-// Простейший WebSocket для личных сообщений (без роутинга и комнат)
-func MessagesWebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// sendConversationMessage сохраняет сообщение в переписку, где отправитель участник
+func sendConversationMessage(ctx context.Context, pool *pgxpool.Pool, req SendMessageRequest) (*models.Message, map[int64]int64, *apiError) {
+	if req.ConversationID < 0 {
+		return nil, nil, &apiError{http.StatusBadRequest, "invalid conversation_id"}
+	}
+
+	msg, seqs, ok, err := db.SendMessage(ctx, pool, req.ConversationID, req.FromUserID, req.Content)
 	if err != nil {
-		http.Error(w, "failed to upgrade", http.StatusBadRequest)
-		return
+		log.Error().Err(err).Int64("user_id", req.FromUserID).Int64("conversation_id", req.ConversationID).Msg("failed to send message")
+		return nil, nil, &apiError{http.StatusInternalServerError, "failed to send message"}
 	}
-	defer conn.Close()
+	if !ok {
+		return nil, nil, &apiError{http.StatusNotFound, "conversation not found"}
+	}
+	return msg, seqs, nil
+}
 
-	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			return
-		}
-		// Эхо-сервер: в реальном коде нужен брокер/room manager, фильтрация по пользователям
-		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-			return
-		}
+// broadcastMessage рассылает message.new участникам; у каждого в событии свой seq
+func broadcastMessage(msg *models.Message, seqs map[int64]int64, origin *hub.Client) {
+	for userID, seq := range seqs {
+		m := *msg
+		m.Seq = seq
+		broadcast([]int64{userID}, newEvent(protocol.TypeMessageNew, "", m), origin)
 	}
 }
 
+// handleWSMessageSend — кадр message.send: отправителю message.ack, остальным сокетам message.new
+func handleWSMessageSend(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var payload protocol.MessageSendPayload
+	if apiErr := decodeWSPayload(env, &payload); apiErr != nil {
+		return apiErr
+	}
+
+	msg, apiErr := sendMessage(r.Context(), SendMessageRequest{
+		FromUserID:     auth.user.ID,
+		ToUserID:       payload.ToUserID,
+		ConversationID: payload.ConversationID,
+		Content:        payload.Content,
+	}, c)
+	if apiErr != nil {
+		return apiErr
+	}
 
+	sendEvent(c, newEvent(protocol.TypeMessageAck, env.ID, msg))
+	return nil
+}
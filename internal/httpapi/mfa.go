@@ -0,0 +1,417 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/identity"
+	"github.com/yeoboseyo/server/internal/models"
+	"github.com/yeoboseyo/server/internal/totp"
+)
+
+const (
+	tokenTypeMFAPending = "mfa_pending"
+	// Сколько есть времени, чтобы ввести код после первого фактора
+	mfaPendingTTL = 5 * time.Minute
+	// Сколько кодов восстановления выдаём
+	recoveryCodeCount = 10
+)
+
+var (
+	// Название сервиса в приложении-аутентификаторе
+	mfaIssuer = "Yeoboseyo"
+
+	// Ограничение попыток ввода кода на пользователя
+	mfaAttemptsPerUser = newRateLimiter(10, 15*time.Minute)
+)
+
+func init() {
+	if v := os.Getenv("MFA_ISSUER"); v != "" {
+		mfaIssuer = v
+	}
+}
+
+// mfaPendingClaims — пройден первый фактор, ждём TOTP-код или код восстановления
+type mfaPendingClaims struct {
+	UserID   int64  `json:"user_id"`
+	Provider string `json:"provider"`
+	Subject  string `json:"sub_provider"`
+	Type     string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// requireMFA вместо токенов отдаёт короткоживущий mfa_token, который нужно
+// обменять через POST /auth/mfa/verify вместе с кодом.
+func requireMFA(w http.ResponseWriter, r *http.Request, userID int64, ident *identity.Identity, returnTo string) {
+	claims := mfaPendingClaims{
+		UserID:   userID,
+		Provider: ident.Provider,
+		Subject:  ident.Subject,
+		Type:     tokenTypeMFAPending,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaPendingTTL)),
+		},
+	}
+
+	mfaToken, err := signJWT(claims)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to sign jwt")
+		return
+	}
+
+	log.Info().Int64("user_id", userID).Str("provider", ident.Provider).Msg("mfa required")
+
+	if returnTo != "" {
+		redirectWithFragment(w, r, returnTo, url.Values{
+			"mfa_required": {"true"},
+			"mfa_token":    {mfaToken},
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"expires_in":   int64(mfaPendingTTL.Seconds()),
+	})
+}
+
+type mfaVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAVerifyHandler завершает вход: проверяет TOTP-код или код восстановления
+// и выдаёт обычную пару токенов.
+func MFAVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var req mfaVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid mfa_token")
+		return
+	}
+
+	claims := &mfaPendingClaims{}
+	err := parseJWT(req.MFAToken, claims)
+	if err != nil || claims.Type != tokenTypeMFAPending || claims.UserID == 0 {
+		writeError(w, http.StatusUnauthorized, "invalid mfa_token")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), claims.UserID, req.Code, req.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, errTooManyAttempts) {
+			writeError(w, http.StatusTooManyRequests, "too many attempts")
+			return
+		}
+		log.Error().Err(err).Int64("user_id", claims.UserID).Msg("failed to verify second factor")
+		writeError(w, http.StatusInternalServerError, "failed to verify code")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), pool, claims.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", claims.UserID).Msg("failed to load user")
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "user not found")
+		return
+	}
+
+	// verify вызывает фронтенд, а не браузерный редирект — токены отдаём JSON
+	finishLogin(w, r, user, claims.Provider, claims.Subject, "")
+}
+
+// MFAStatusHandler сообщает, включён ли второй фактор и сколько осталось кодов восстановления
+func MFAStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mfa, err := db.GetUserMFA(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to get user mfa")
+		writeError(w, http.StatusInternalServerError, "failed to get mfa status")
+		return
+	}
+
+	remaining := 0
+	if mfa.Enabled() {
+		remaining, err = db.CountRecoveryCodes(r.Context(), pool, user.ID)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to count recovery codes")
+			writeError(w, http.StatusInternalServerError, "failed to get mfa status")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":                  mfa.Enabled(),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// MFAEnrollHandler начинает подключение TOTP: генерирует секрет и otpauth:// ссылку для QR.
+// Фактор не действует, пока не подтверждён через /api/mfa/confirm.
+func MFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to enroll")
+		return
+	}
+
+	if err := db.StartMFAEnrollment(r.Context(), pool, user.ID, secret); err != nil {
+		if errors.Is(err, db.ErrMFAAlreadyEnabled) {
+			writeError(w, http.StatusConflict, "mfa already enabled")
+			return
+		}
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to start mfa enrollment")
+		writeError(w, http.StatusInternalServerError, "failed to enroll")
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Name
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(mfaIssuer, account, secret),
+	})
+}
+
+type mfaCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAConfirmHandler включает TOTP после ввода первого кода из приложения
+// и один раз показывает коды восстановления.
+func MFAConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+
+	if !mfaAttemptsPerUser.Allow(strconv.FormatInt(user.ID, 10)) {
+		writeError(w, http.StatusTooManyRequests, "too many attempts")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	mfa, err := db.GetUserMFA(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to get user mfa")
+		writeError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	if mfa == nil {
+		writeError(w, http.StatusConflict, "mfa enrollment not started")
+		return
+	}
+	if mfa.Enabled() {
+		writeError(w, http.StatusConflict, "mfa already enabled")
+		return
+	}
+
+	step, ok := matchTOTP(mfa, req.Code, time.Now())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+
+	if err := db.ConfirmMFA(r.Context(), pool, user.ID, step, hashes); err != nil {
+		if errors.Is(err, db.ErrMFAAlreadyEnabled) {
+			writeError(w, http.StatusConflict, "mfa already enabled")
+			return
+		}
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to confirm mfa")
+		writeError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Msg("mfa enabled")
+	writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+}
+
+// MFADisableHandler отключает второй фактор; требует действующий код или код восстановления
+func MFADisableHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		writeError(w, http.StatusBadRequest, "code or recovery_code required")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, errTooManyAttempts) {
+			writeError(w, http.StatusTooManyRequests, "too many attempts")
+			return
+		}
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to verify second factor")
+		writeError(w, http.StatusInternalServerError, "failed to verify code")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+
+	if err := db.DisableMFA(r.Context(), pool, user.ID); err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to disable mfa")
+		writeError(w, http.StatusInternalServerError, "failed to disable mfa")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Msg("mfa disabled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errTooManyAttempts = errors.New("too many attempts")
+
+// checkSecondFactor проверяет TOTP-код (однократно) или погашает код восстановления
+func checkSecondFactor(ctx context.Context, userID int64, code, recoveryCode string) (bool, error) {
+	if !mfaAttemptsPerUser.Allow(strconv.FormatInt(userID, 10)) {
+		return false, errTooManyAttempts
+	}
+
+	pool := DB()
+
+	mfa, err := db.GetUserMFA(ctx, pool, userID)
+	if err != nil {
+		return false, err
+	}
+	if !mfa.Enabled() {
+		return false, nil
+	}
+
+	if code != "" {
+		step, ok := matchTOTP(mfa, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return db.UseTOTPStep(ctx, pool, userID, step)
+	}
+
+	if recoveryCode != "" {
+		ok, err := db.UseRecoveryCode(ctx, pool, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if ok {
+			log.Info().Int64("user_id", userID).Msg("recovery code used")
+		}
+		return ok, err
+	}
+
+	return false, nil
+}
+
+// matchTOTP проверяет код с допуском в один шаг в обе стороны и отклоняет шаги,
+// не новее последнего принятого. Гонку двух одновременных входов отсекает db.UseTOTPStep.
+func matchTOTP(mfa *models.UserMFA, code string, now time.Time) (int64, bool) {
+	step, ok := totp.Validate(mfa.Secret, code, now, 1)
+	if !ok || step <= mfa.LastUsedStep {
+		return 0, false
+	}
+	return step, true
+}
+
+// Алфавит кодов восстановления без похожих символов (0/o, 1/l)
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes генерирует коды вида xxxxx-xxxxx и их хеши для БД
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomFromAlphabet(recoveryAlphabet, 10)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode убирает дефисы, пробелы и регистр, чтобы код можно было вводить как удобно
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.Join(strings.Fields(code), "")
+}
+
+func randomFromAlphabet(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate code: %w", err)
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
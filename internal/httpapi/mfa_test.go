@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Секрет и коды из RFC 6238, приложение B (последние 6 цифр)
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestMatchTOTPWindow(t *testing.T) {
+	mfa := &models.UserMFA{Secret: testTOTPSecret}
+	at := time.Unix(1111111109, 0) // код 081804, шаг 37037036
+
+	cases := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"current step", 0, true},
+		{"clock behind by one step", 30 * time.Second, true},
+		{"clock ahead by one step", -30 * time.Second, true},
+		{"two steps late", 60 * time.Second, false},
+		{"two steps early", -60 * time.Second, false},
+	}
+	for _, c := range cases {
+		step, ok := matchTOTP(mfa, "081804", at.Add(c.offset))
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+		}
+		if ok && step != 37037036 {
+			t.Errorf("%s: step = %d, want 37037036", c.name, step)
+		}
+	}
+}
+
+func TestMatchTOTPRejectsReplay(t *testing.T) {
+	at := time.Unix(1111111111, 0) // код 050471, шаг 37037037
+
+	// Код уже принимался — повтор в пределах окна отклоняется
+	used := &models.UserMFA{Secret: testTOTPSecret, LastUsedStep: 37037037}
+	if _, ok := matchTOTP(used, "050471", at); ok {
+		t.Fatal("expected replayed code to be rejected")
+	}
+
+	// Принят более поздний код — более ранний из окна тоже не проходит
+	if _, ok := matchTOTP(used, "081804", at); ok {
+		t.Fatal("expected older code to be rejected after a newer one was used")
+	}
+
+	fresh := &models.UserMFA{Secret: testTOTPSecret, LastUsedStep: 37037036}
+	step, ok := matchTOTP(fresh, "050471", at)
+	if !ok || step != 37037037 {
+		t.Fatalf("expected fresh code to be accepted, got %d, %v", step, ok)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatalf("newRecoveryCodes: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d/%d", recoveryCodeCount, len(codes), len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+
+		// Код можно ввести в любом регистре, с пробелами и без дефиса
+		for _, typed := range []string{
+			strings.ToUpper(code),
+			" " + code[:5] + " " + code[6:] + " ",
+			strings.ToUpper(code[:5]) + code[6:],
+			code[:5] + strings.ToUpper(code[5:]),
+		} {
+			if hashToken(normalizeRecoveryCode(typed)) != hashes[i] {
+				t.Errorf("normalized %q does not match stored hash", typed)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/yeoboseyo/server/internal/identity"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+	tokenTypeOAuth   = "oauth_state"
+)
+
+// Разрешённые origin'ы для return_to (через запятую), напр. "http://localhost:3000,https://app.example.com"
+var returnToAllowed = parseOrigins(os.Getenv("AUTH_RETURN_TO_ALLOWED"))
+
+// oauthState — то, что мы запоминаем между редиректом на провайдера и callback'ом.
+// Хранится в подписанной короткоживущей HttpOnly-куке, поэтому на сервере состояния нет.
+type oauthState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+	Nonce    string `json:"nonce"`
+	ReturnTo string `json:"return_to,omitempty"`
+	// Ненулевой — это не логин, а привязка способа входа к уже залогиненному пользователю
+	LinkUserID int64  `json:"link_user_id,omitempty"`
+	Type       string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// newOAuthState генерирует случайные state, nonce и PKCE verifier для одного логина
+func newOAuthState(provider, returnTo string) (*oauthState, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthState{
+		Provider: provider,
+		State:    state,
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    nonce,
+		ReturnTo: returnTo,
+		Type:     tokenTypeOAuth,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oauthStateTTL)),
+		},
+	}, nil
+}
+
+// oauthStateCookieFor собирает куку со state, видимую только callback'у провайдера
+func oauthStateCookieFor(p identity.Provider, value string, maxAge int) *http.Cookie {
+	c := &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    value,
+		Path:     "/auth/" + p.Name() + "/callback",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		// Ставим Secure, если callback работает по https
+		Secure: strings.HasPrefix(p.RedirectURL(), "https://"),
+		// Lax: кука должна уйти на top-level редирект от провайдера обратно к нам
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	// При form_post провайдер возвращает пользователя cross-site POST'ом,
+	// Lax-куку браузер на такой запрос не отправит
+	if p.FormPost() {
+		c.SameSite = http.SameSiteNoneMode
+		c.Secure = true
+	}
+
+	return c
+}
+
+// setOAuthStateCookie подписывает состояние и кладёт его в куку
+func setOAuthStateCookie(w http.ResponseWriter, p identity.Provider, st *oauthState) error {
+	signed, err := signJWT(st)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, oauthStateCookieFor(p, signed, int(oauthStateTTL.Seconds())))
+	return nil
+}
+
+// popOAuthState достаёт состояние из куки, удаляет куку (одноразовость)
+// и сверяет state из запроса с сохранённым.
+func popOAuthState(w http.ResponseWriter, r *http.Request, p identity.Provider) (*oauthState, error) {
+	cookie, err := r.Cookie(oauthStateCookie)
+
+	http.SetCookie(w, oauthStateCookieFor(p, "", -1))
+
+	if err != nil {
+		return nil, errors.New("missing state cookie")
+	}
+
+	st := &oauthState{}
+	err = parseJWT(cookie.Value, st)
+	if err != nil {
+		return nil, errors.New("invalid state cookie")
+	}
+	if st.Type != tokenTypeOAuth || st.Provider != p.Name() {
+		return nil, errors.New("invalid state cookie")
+	}
+
+	got := r.FormValue("state")
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(st.State)) != 1 {
+		return nil, errors.New("state mismatch")
+	}
+
+	return st, nil
+}
+
+// validReturnTo проверяет, что return_to — абсолютный URL на одном из разрешённых origin'ов
+func validReturnTo(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return false
+	}
+
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range returnToAllowed {
+		if origin == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redirectWithTokens возвращает пользователя на фронтенд; токены передаём во фрагменте,
+// чтобы они не попадали в логи серверов и заголовок Referer.
+func redirectWithTokens(w http.ResponseWriter, r *http.Request, returnTo string, tokens *tokenResponse) {
+	fragment := url.Values{}
+	fragment.Set("token", tokens.Token)
+	fragment.Set("refresh_token", tokens.RefreshToken)
+	fragment.Set("expires_in", strconv.FormatInt(tokens.ExpiresIn, 10))
+
+	redirectWithFragment(w, r, returnTo, fragment)
+}
+
+// redirectWithFragment редиректит на return_to, заменяя его фрагмент на переданные значения
+func redirectWithFragment(w http.ResponseWriter, r *http.Request, returnTo string, fragment url.Values) {
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid return_to")
+		return
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	http.Redirect(w, r, u.String()+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func parseOrigins(raw string) []string {
+	var origins []string
+	for _, o := range strings.Split(raw, ",") {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		if o != "" {
+			origins = append(origins, strings.ToLower(o))
+		}
+	}
+	return origins
+}
@@ -0,0 +1,214 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Персональные токены отличаются от JWT по префиксу (и их легко найти сканерам секретов)
+const patPrefix = "ybs_pat_"
+
+// Наибольший expires_in (год, в секундах); больше — ошибка, а не переполнение time.Duration
+const maxExpiresIn = 365 * 24 * 60 * 60
+
+// Scope'ы персональных токенов. Интерактивной сессии (JWT) доступно всё.
+const (
+	scopeProfileRead   = "profile:read"
+	scopeMessagesRead  = "messages:read"
+	scopeMessagesWrite = "messages:write"
+	scopeCalls         = "calls"
+)
+
+var knownScopes = []string{scopeProfileRead, scopeMessagesRead, scopeMessagesWrite, scopeCalls}
+
+// routeScopes — маршруты, доступные по персональным токенам, и нужный для них scope.
+// Маршрута нет в карте — он только для интерактивной сессии (управление аккаунтом, токенами и т.п.).
+var routeScopes = map[*mux.Route]string{}
+
+// withScope разрешает маршрут персональным токенам со scope
+func withScope(route *mux.Route, scope string) {
+	routeScopes[route] = scope
+}
+
+// checkTokenScope проверяет, что персональному токену можно вызвать текущий маршрут
+func checkTokenScope(r *http.Request, pat *models.PersonalAccessToken) (ok bool, msg string) {
+	scope, allowed := routeScopes[mux.CurrentRoute(r)]
+	if !allowed {
+		return false, "not available for api tokens"
+	}
+	if !pat.HasScope(scope) {
+		return false, "token lacks scope " + scope
+	}
+	return true, ""
+}
+
+type createTokenRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int64    `json:"expires_in,omitempty"` // в секундах; 0 — бессрочный
+	BotID     int64    `json:"bot_id,omitempty"`     // выпустить токен для своего бота
+}
+
+// createdTokenResponse — сам токен показывается только один раз, при создании
+type createdTokenResponse struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// CreateTokenHandler выпускает персональный токен для текущего пользователя или его бота
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	var req createTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+	for _, s := range req.Scopes {
+		if !slices.Contains(knownScopes, s) {
+			writeError(w, http.StatusBadRequest, "unknown scope "+s)
+			return
+		}
+	}
+	expiresAt, ok := expiresAtFrom(req.ExpiresIn)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid expires_in")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ownerID := user.ID
+	if req.BotID != 0 {
+		bot, err := db.GetBot(r.Context(), pool, user.ID, req.BotID)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", user.ID).Int64("bot_id", req.BotID).Msg("failed to get bot")
+			writeError(w, http.StatusInternalServerError, "failed to create token")
+			return
+		}
+		if bot == nil {
+			writeError(w, http.StatusNotFound, "bot not found")
+			return
+		}
+		ownerID = bot.ID
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	raw := patPrefix + secret
+
+	pat, err := db.CreatePersonalAccessToken(
+		r.Context(),
+		pool,
+		ownerID,
+		user.ID,
+		truncate(req.Name, 255),
+		hashToken(raw),
+		raw[:len(patPrefix)+4],
+		slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		expiresAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to create personal access token")
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+
+	log.Info().Int64("user_id", user.ID).Int64("token_user_id", ownerID).Int64("token_id", pat.ID).Strs("scopes", pat.Scopes).Msg("personal access token created")
+
+	writeJSON(w, http.StatusCreated, createdTokenResponse{PersonalAccessToken: *pat, Token: raw})
+}
+
+// ListTokensHandler отдаёт действующие токены пользователя и его ботов (без самих токенов)
+func ListTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	tokens, err := db.ListPersonalAccessTokens(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list personal access tokens")
+		writeError(w, http.StatusInternalServerError, "failed to list tokens")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeTokenHandler отзывает токен пользователя или его бота
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	tokenID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid token id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.RevokePersonalAccessToken(r.Context(), pool, user.ID, tokenID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("token_id", tokenID).Msg("failed to revoke personal access token")
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "token not found")
+		return
+	}
+
+	wsHub.CloseToken(tokenID, hub.CloseUnauthorized, "token revoked")
+
+	log.Info().Int64("user_id", user.ID).Int64("token_id", tokenID).Msg("personal access token revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expiresAtFrom переводит expires_in (в секундах, 0 — бессрочно) в момент истечения.
+// ok=false — значение отрицательное или больше maxExpiresIn.
+func expiresAtFrom(expiresIn int64) (expiresAt *time.Time, ok bool) {
+	if expiresIn < 0 || expiresIn > maxExpiresIn {
+		return nil, false
+	}
+	if expiresIn == 0 {
+		return nil, true
+	}
+	t := time.Now().Add(time.Duration(expiresIn) * time.Second)
+	return &t, true
+}
@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExpiresAtFrom(t *testing.T) {
+	if at, ok := expiresAtFrom(0); !ok || at != nil {
+		t.Fatalf("expires_in=0 must mean no expiry, got %v, %v", at, ok)
+	}
+
+	at, ok := expiresAtFrom(3600)
+	if !ok || at == nil {
+		t.Fatal("expected expiry for expires_in=3600")
+	}
+	if d := time.Until(*at); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("unexpected expiry in %s", d)
+	}
+
+	if at, ok := expiresAtFrom(maxExpiresIn); !ok || at.Before(time.Now()) {
+		t.Fatal("expected maximal expires_in to be accepted")
+	}
+
+	// Значения, которые раньше переполняли time.Duration, отклоняются
+	for _, v := range []int64{-1, maxExpiresIn + 1, math.MaxInt64 / int64(time.Second) * 2, math.MaxInt64} {
+		if _, ok := expiresAtFrom(v); ok {
+			t.Errorf("expires_in=%d must be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+// Через сколько без входящих кадров соединение считается неактивным (idle)
+var presenceIdleAfter = envDuration("PRESENCE_IDLE_AFTER", 5*time.Minute)
+
+var (
+	// Изменения статуса обрабатываются одним обработчиком, чтобы собеседники
+	// не получили устаревший статус после свежего
+	presenceQueue     = newUserQueue()
+	presenceQueueOnce sync.Once
+)
+
+// queuePresence — подписчик хаба: статус пользователя мог измениться.
+// Вызывается из хаба и цикла чтения, поэтому никогда не ждёт базу.
+func queuePresence(userID int64) {
+	presenceQueueOnce.Do(func() { go presenceQueue.run(presenceWorker()) })
+	presenceQueue.push(userID)
+}
+
+// presenceWorker рассылает изменения статуса собеседникам и сохраняет last_seen_at
+// при уходе из сети. Статус берётся из хаба в момент обработки, поэтому
+// несколько изменений подряд схлопываются в одно событие.
+func presenceWorker() func(userID int64) {
+	sent := map[int64]string{}
+	return func(userID int64) {
+		status := wsHub.Status(userID)
+		prev, known := sent[userID]
+		if prev == status || (!known && status == hub.StatusOffline) {
+			return
+		}
+		if status == hub.StatusOffline {
+			delete(sent, userID)
+		} else {
+			sent[userID] = status
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		publishPresence(ctx, userID, status)
+		cancel()
+	}
+}
+
+// userQueue — очередь пользователей, ожидающих обработки. push не блокируется:
+// пользователь, который уже ждёт в очереди, повторно не добавляется.
+type userQueue struct {
+	mu      sync.Mutex
+	pending map[int64]struct{}
+	order   []int64
+	wake    chan struct{}
+}
+
+func newUserQueue() *userQueue {
+	return &userQueue{
+		pending: map[int64]struct{}{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (q *userQueue) push(userID int64) {
+	q.mu.Lock()
+	if _, ok := q.pending[userID]; !ok {
+		q.pending[userID] = struct{}{}
+		q.order = append(q.order, userID)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // обработчик уже разбужен
+	}
+}
+
+// take забирает всех ожидающих в порядке постановки
+func (q *userQueue) take() []int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	batch := q.order
+	q.order = nil
+	clear(q.pending)
+	return batch
+}
+
+// run обрабатывает очередь до конца работы процесса
+func (q *userQueue) run(fn func(userID int64)) {
+	for range q.wake {
+		for batch := q.take(); len(batch) > 0; batch = q.take() {
+			for _, userID := range batch {
+				fn(userID)
+			}
+		}
+	}
+}
+
+func publishPresence(ctx context.Context, userID int64, status string) {
+	pool := DB()
+	if pool == nil {
+		return
+	}
+
+	payload := protocol.PresencePayload{UserID: userID, Status: status}
+	if status == hub.StatusOffline {
+		now := time.Now()
+		if err := db.SetLastSeen(ctx, pool, userID, now); err != nil {
+			log.Error().Err(err).Int64("user_id", userID).Msg("failed to save last seen")
+		}
+		user, err := db.GetUserByID(ctx, pool, userID)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", userID).Msg("failed to load user for presence")
+		}
+		if user != nil && !user.HideLastSeen {
+			payload.LastSeenAt = &now
+		}
+	}
+
+	partners, err := db.ListConversationPartnerIDs(ctx, pool, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("failed to list conversation partners")
+		return
+	}
+	partners = slices.DeleteFunc(partners, func(id int64) bool { return !wsHub.Online(id) })
+
+	broadcast(partners, newEvent(protocol.TypePresence, "", payload), nil)
+}
+
+// handleWSPresence — кадр presence: клиент сообщает, активен ли он
+func handleWSPresence(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var payload protocol.PresencePayload
+	if apiErr := decodeWSPayload(env, &payload); apiErr != nil {
+		return apiErr
+	}
+	c.SetIdle(payload.Status == protocol.PresenceIdle)
+	return nil
+}
+
+// PresenceHandler отдаёт статус пользователя. Доступен ему самому и тем,
+// с кем у него есть общая переписка; last_seen_at — если он его не скрыл.
+func PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	if userID != user.ID {
+		shared, err := db.SharesConversation(r.Context(), pool, user.ID, userID)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", user.ID).Int64("target_user_id", userID).Msg("failed to check shared conversation")
+			writeError(w, http.StatusInternalServerError, "failed to get presence")
+			return
+		}
+		if !shared {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+	}
+
+	target, err := db.GetUserByID(r.Context(), pool, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("failed to load user for presence")
+		writeError(w, http.StatusInternalServerError, "failed to get presence")
+		return
+	}
+	if target == nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	resp := protocol.PresencePayload{UserID: target.ID, Status: wsHub.Status(target.ID)}
+	if resp.Status == hub.StatusOffline && (!target.HideLastSeen || target.ID == user.ID) {
+		resp.LastSeenAt = target.LastSeenAt
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
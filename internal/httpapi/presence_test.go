@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// push не ждёт занятого обработчика, а повторы ожидающих пользователей схлопываются
+func TestUserQueueCoalesces(t *testing.T) {
+	q := newUserQueue()
+
+	started := make(chan int64)
+	release := make(chan struct{})
+	processed := make(chan int64, 16)
+	first := true
+	go q.run(func(userID int64) {
+		if first {
+			first = false
+			started <- userID
+			<-release
+		}
+		processed <- userID
+	})
+
+	q.push(1)
+	<-started
+
+	// Обработчик занят: тысячи изменений не блокируют отправителя
+	pushed := make(chan struct{})
+	go func() {
+		for range 1000 {
+			q.push(2)
+			q.push(3)
+			q.push(2)
+		}
+		q.push(1) // статус изменился, пока его обрабатывали
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked while the worker was busy")
+	}
+	close(release)
+
+	var got []int64
+	for len(got) < 4 {
+		select {
+		case id := <-processed:
+			got = append(got, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	if want := []int64{1, 2, 3, 1}; !slices.Equal(got, want) {
+		t.Fatalf("processed %v, want %v", got, want)
+	}
+
+	select {
+	case id := <-processed:
+		t.Fatalf("unexpected extra processing of %d", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/identity"
+)
+
+// identityProviders — настроенные через env провайдеры входа (/auth/{provider}/...)
+var identityProviders = identity.NewRegistry()
+
+// Имя провайдера становится сегментом URL, поэтому ограничиваем алфавит
+var providerNameRe = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+func init() {
+	// Google (напр. GOOGLE_REDIRECT_URL=http://localhost:8080/auth/google/callback)
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		identityProviders.Register(identity.NewGoogle(
+			id,
+			os.Getenv("GOOGLE_CLIENT_SECRET"),
+			os.Getenv("GOOGLE_REDIRECT_URL"),
+			os.Getenv("GOOGLE_JWKS_URL"), // можно направить на локальный фейковый сервер ключей
+		))
+	}
+
+	// GitHub (для GitHub Enterprise задаётся GITHUB_API_URL)
+	if id := os.Getenv("GITHUB_CLIENT_ID"); id != "" {
+		identityProviders.Register(identity.NewGitHub(identity.GitHubConfig{
+			ClientID:     id,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+			APIURL:       os.Getenv("GITHUB_API_URL"),
+		}))
+	}
+
+	// Sign in with Apple
+	if id := os.Getenv("APPLE_CLIENT_ID"); id != "" {
+		identityProviders.Register(identity.NewApple(
+			id,
+			os.Getenv("APPLE_CLIENT_SECRET"),
+			os.Getenv("APPLE_REDIRECT_URL"),
+		))
+	}
+
+	// Произвольные OIDC-провайдеры (Keycloak и т.п.): OIDC_PROVIDERS=keycloak,okta
+	// и для каждого OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL, _SCOPES
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNameRe.MatchString(name) {
+			log.Warn().Str("provider", name).Msg("invalid oidc provider name, skipping")
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg := identity.OIDCConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			log.Warn().Str("provider", name).Msg("oidc provider is missing issuer or client id, skipping")
+			continue
+		}
+
+		identityProviders.Register(identity.NewOIDC(cfg))
+	}
+
+	log.Info().Strs("providers", identityProviders.Names()).Msg("identity providers configured")
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter — in-memory лимитер с фиксированным окном: не больше limit событий
+// на ключ за window. Состояние живёт в памяти одного инстанса сервера.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	hits      map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string]*rateWindow),
+	}
+}
+
+// Allow учитывает событие для ключа и сообщает, укладывается ли оно в лимит
+func (l *rateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.hits[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.hits[key] = &rateWindow{start: now, count: 1}
+		return true
+	}
+
+	if w.count >= l.limit {
+		return false
+	}
+
+	w.count++
+	return true
+}
+
+// sweep раз в окно выбрасывает истёкшие записи, чтобы карта не росла бесконечно
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, w := range l.hits {
+		if now.Sub(w.start) >= l.window {
+			delete(l.hits, key)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWindow(t *testing.T) {
+	l := newRateLimiter(3, 100*time.Millisecond)
+
+	for i := range 3 {
+		if !l.Allow("a") {
+			t.Fatalf("event %d within the limit was rejected", i)
+		}
+	}
+	if l.Allow("a") {
+		t.Fatal("expected event over the limit to be rejected")
+	}
+	if !l.Allow("b") {
+		t.Fatal("keys must be limited independently")
+	}
+
+	// Отклонённые события окно не продлевают: после него лимит снова полный
+	time.Sleep(120 * time.Millisecond)
+	for i := range 3 {
+		if !l.Allow("a") {
+			t.Fatalf("event %d in the next window was rejected", i)
+		}
+	}
+	if l.Allow("a") {
+		t.Fatal("expected the new window to be limited too")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := newRateLimiter(1, 50*time.Millisecond)
+
+	l.Allow("a")
+	l.Allow("b")
+	time.Sleep(60 * time.Millisecond)
+	l.Allow("c")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.hits["a"]; ok {
+		t.Fatal("expected expired key a to be swept")
+	}
+	if _, ok := l.hits["b"]; ok {
+		t.Fatal("expected expired key b to be swept")
+	}
+	if len(l.hits) != 1 {
+		t.Fatalf("expected only the fresh key to remain, got %d", len(l.hits))
+	}
+}
@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+// Лимит изменений реакций от пользователя: защищает участников от потока событий
+var reactionsPerUser = newRateLimiter(60, time.Minute)
+
+// validEmoji — короткая последовательность без пробелов и управляющих символов,
+// в которой есть хотя бы один не-ASCII символ (сами эмодзи, модификаторы, ZWJ)
+func validEmoji(s string) bool {
+	if s == "" || len(s) > 64 || utf8.RuneCountInString(s) > 16 || !utf8.ValidString(s) {
+		return false
+	}
+	nonASCII := false
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+		if r > unicode.MaxASCII {
+			nonASCII = true
+		}
+	}
+	return nonASCII
+}
+
+// AddReactionHandler ставит реакцию {emoji} на сообщение и рассылает reaction.added участникам
+func AddReactionHandler(w http.ResponseWriter, r *http.Request) {
+	changeReaction(w, r, true)
+}
+
+// RemoveReactionHandler снимает реакцию {emoji} и рассылает reaction.removed участникам
+func RemoveReactionHandler(w http.ResponseWriter, r *http.Request) {
+	changeReaction(w, r, false)
+}
+
+func changeReaction(w http.ResponseWriter, r *http.Request, add bool) {
+	user := CurrentUser(r.Context())
+
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+	emoji := mux.Vars(r)["emoji"]
+	if !validEmoji(emoji) {
+		writeError(w, http.StatusBadRequest, "invalid emoji")
+		return
+	}
+
+	if !reactionsPerUser.Allow(strconv.FormatInt(user.ID, 10)) {
+		writeError(w, http.StatusTooManyRequests, "too many reactions")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	var msg *models.Message
+	var changed bool
+	typ := protocol.TypeReactionAdded
+	if add {
+		msg, changed, err = db.AddReaction(r.Context(), pool, messageID, user.ID, emoji)
+	} else {
+		msg, changed, err = db.RemoveReaction(r.Context(), pool, messageID, user.ID, emoji)
+		typ = protocol.TypeReactionRemoved
+	}
+	if errors.Is(err, db.ErrMessageDeleted) {
+		writeError(w, http.StatusConflict, "message is deleted")
+		return
+	}
+	if errors.Is(err, db.ErrSystemMessage) {
+		writeError(w, http.StatusBadRequest, "cannot react to a system message")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("message_id", messageID).Msg("failed to change reaction")
+		writeError(w, http.StatusInternalServerError, "failed to change reaction")
+		return
+	}
+	if msg == nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	if changed {
+		broadcastToMembers(r.Context(), msg.ConversationID, newEvent(typ, "", protocol.ReactionPayload{
+			ConversationID: msg.ConversationID,
+			MessageID:      msg.ID,
+			UserID:         user.ID,
+			Emoji:          emoji,
+		}))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidEmoji(t *testing.T) {
+	cases := map[string]bool{
+		"👍":          true,
+		"❤️":         true, // с вариационным селектором
+		"👍🏽":         true, // с модификатором тона кожи
+		"👨‍👩‍👧":      true, // последовательность с ZWJ
+		"🇰🇷":         true, // флаг из двух региональных индикаторов
+		"":           false,
+		"+1":         false, // только ASCII
+		":thumbsup:": false,
+		"👍 ":         false,
+		"👍\n":        false,
+		"👍\u0000":    false,
+		"\xff\xfe":   false, // не UTF-8
+	}
+	cases[strings.Repeat("👍", 17)] = false // больше 16 символов
+	cases[strings.Repeat("🇰🇷", 8)] = true
+	cases[strings.Repeat("é", 33)] = false // больше 64 байт
+
+	for s, want := range cases {
+		if got := validEmoji(s); got != want {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+}
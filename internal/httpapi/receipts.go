@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+type markReadRequest struct {
+	MessageID int64 `json:"message_id"` // прочитано всё до этого сообщения включительно
+}
+
+// MarkReadHandler сдвигает отметку прочтения переписки и рассылает отправителям
+// прочитанных сообщений событие receipt
+func MarkReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	conversationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversation id")
+		return
+	}
+
+	var req markReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.MessageID <= 0 {
+		writeError(w, http.StatusBadRequest, "message_id is required")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	now := time.Now()
+	updates, readUpTo, ok, err := db.MarkRead(r.Context(), pool, conversationID, user.ID, req.MessageID, now)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("conversation_id", conversationID).Msg("failed to mark conversation read")
+		writeError(w, http.StatusInternalServerError, "failed to mark read")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "conversation not found")
+		return
+	}
+
+	notifyReceipts(user.ID, protocol.ReceiptRead, now, updates)
+	if readUpTo > 0 {
+		// Другие устройства читателя обновляют счётчик непрочитанных
+		broadcast([]int64{user.ID}, newEvent(protocol.TypeReceipt, "", protocol.ReceiptPayload{
+			ConversationID: conversationID,
+			UserID:         user.ID,
+			MessageID:      readUpTo,
+			Kind:           protocol.ReceiptRead,
+			At:             now,
+		}), nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyReceipts сообщает отправителям, что userID получил или прочитал их сообщения
+func notifyReceipts(userID int64, kind string, at time.Time, updates []db.ReceiptUpdate) {
+	for _, u := range updates {
+		broadcast([]int64{u.SenderID}, newEvent(protocol.TypeReceipt, "", protocol.ReceiptPayload{
+			ConversationID: u.ConversationID,
+			UserID:         userID,
+			MessageID:      u.MessageID,
+			Kind:           kind,
+			At:             at,
+		}), nil)
+	}
+}
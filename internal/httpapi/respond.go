@@ -0,0 +1,25 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeJSON сериализует v в JSON и отдаёт с указанным статусом.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// apiError — ошибка, которую нужно отдать клиенту: HTTP-статус и сообщение.
+// Для хелперов, общих у HTTP-хендлеров и WebSocket.
+type apiError struct {
+	status int
+	msg    string
+}
+
+// writeError отдаёт ошибку в виде {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -7,26 +7,127 @@ import (
 )
 
 func RegisterRoutes(r *mux.Router) {
-	// Auth
-	r.HandleFunc("/auth/google/login", GoogleLoginHandler).Methods(http.MethodGet)
-	r.HandleFunc("/auth/google/callback", GoogleCallbackHandler).Methods(http.MethodGet)
+	// Публичные ключи для проверки наших JWT другими сервисами
+	r.HandleFunc("/.well-known/jwks.json", JWKSHandler).Methods(http.MethodGet)
+
+	// Auth через внешних провайдеров: google, github, apple, OIDC из OIDC_PROVIDERS
+	r.HandleFunc("/auth/{provider}/login", ProviderLoginHandler).Methods(http.MethodGet)
+	// POST — для провайдеров с response_mode=form_post (Apple)
+	r.HandleFunc("/auth/{provider}/callback", ProviderCallbackHandler).Methods(http.MethodGet, http.MethodPost)
 	// POST — основной хендлер, OPTIONS — для CORS preflight
-	r.HandleFunc("/auth/google/frontend", GoogleFrontendAuthHandler).Methods(http.MethodPost)
-	r.HandleFunc("/auth/google/frontend", func(w http.ResponseWriter, r *http.Request) {
+	r.HandleFunc("/auth/{provider}/frontend", ProviderFrontendAuthHandler).Methods(http.MethodPost)
+	r.HandleFunc("/auth/{provider}/frontend", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	}).Methods(http.MethodOptions)
 
-	// Protected API (in будущем можно повесить middleware аутентификации)
-	r.HandleFunc("/api/me", MeHandler).Methods(http.MethodGet)
+	// Вход по email: одноразовая ссылка или код из письма
+	r.HandleFunc("/auth/email/start", EmailLoginStartHandler).Methods(http.MethodPost)
+	r.HandleFunc("/auth/email/verify", EmailLoginVerifyHandler).Methods(http.MethodPost)
+
+	// Вход по QR-коду: новое устройство ждёт, залогиненное подтверждает
+	r.HandleFunc("/auth/link/start", DeviceLinkStartHandler).Methods(http.MethodPost)
+	r.HandleFunc("/auth/link/wait", DeviceLinkWaitHandler).Methods(http.MethodPost)
+	r.Handle("/auth/link/approve", AuthMiddleware(http.HandlerFunc(DeviceLinkApproveHandler))).Methods(http.MethodPost)
+
+	// Второй фактор: обмен mfa_token на токены
+	r.HandleFunc("/auth/mfa/verify", MFAVerifyHandler).Methods(http.MethodPost)
+
+	// Refresh-токены и логаут
+	r.HandleFunc("/auth/refresh", RefreshHandler).Methods(http.MethodPost)
+	r.HandleFunc("/auth/logout", LogoutHandler).Methods(http.MethodPost)
+	r.Handle("/auth/logout-all", AuthMiddleware(http.HandlerFunc(LogoutAllHandler))).Methods(http.MethodPost)
+
+	// JSON Schema протокола сокета (для генерации клиентов)
+	r.HandleFunc("/protocol/{version}.json", ProtocolSchemaHandler).Methods(http.MethodGet)
+
+	// Сокет событий аутентифицируется сам: токен может прийти первым кадром
+	withScope(r.HandleFunc("/api/messages/ws", MessagesWebSocketHandler).Methods(http.MethodGet), scopeMessagesRead)
+
+	// Protected API: все маршруты /api требуют нашего JWT
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(AuthMiddleware)
+
+	// withScope открывает маршрут персональным токенам; остальные — только для интерактивной сессии
+	withScope(api.HandleFunc("/me", MeHandler).Methods(http.MethodGet), scopeProfileRead)
+	api.HandleFunc("/me/settings", UpdateSettingsHandler).Methods(http.MethodPatch)
+
+	// Присутствие собеседников
+	withScope(api.HandleFunc("/users/{id:[0-9]+}/presence", PresenceHandler).Methods(http.MethodGet), scopeProfileRead)
 
-	// Direct messages
-	r.HandleFunc("/api/messages/send", SendMessageHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/messages/ws", MessagesWebSocketHandler).Methods(http.MethodGet)
+	// Устройства (сессии) пользователя
+	api.HandleFunc("/sessions", ListSessionsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{id:[0-9]+}", RevokeSessionHandler).Methods(http.MethodDelete)
+
+	// Способы входа: привязка, отвязка и слияние дублирующих аккаунтов
+	api.HandleFunc("/identities", ListIdentitiesHandler).Methods(http.MethodGet)
+	api.HandleFunc("/identities/{id:[0-9]+}", UnlinkIdentityHandler).Methods(http.MethodDelete)
+	api.HandleFunc("/identities/{provider}/link", StartLinkIdentityHandler).Methods(http.MethodPost)
+	api.HandleFunc("/identities/{provider}", LinkIdentityTokenHandler).Methods(http.MethodPost)
+	api.HandleFunc("/account/merge", MergeAccountHandler).Methods(http.MethodPost)
+
+	// Двухфакторная аутентификация (TOTP)
+	api.HandleFunc("/mfa", MFAStatusHandler).Methods(http.MethodGet)
+	api.HandleFunc("/mfa/enroll", MFAEnrollHandler).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/confirm", MFAConfirmHandler).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/disable", MFADisableHandler).Methods(http.MethodPost)
+
+	// Персональные токены и боты
+	api.HandleFunc("/tokens", ListTokensHandler).Methods(http.MethodGet)
+	api.HandleFunc("/tokens", CreateTokenHandler).Methods(http.MethodPost)
+	api.HandleFunc("/tokens/{id:[0-9]+}", RevokeTokenHandler).Methods(http.MethodDelete)
+	api.HandleFunc("/bots", ListBotsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/bots", CreateBotHandler).Methods(http.MethodPost)
+	api.HandleFunc("/bots/{id:[0-9]+}", DeleteBotHandler).Methods(http.MethodDelete)
+
+	// Переписки и история сообщений
+	withScope(api.HandleFunc("/conversations", ListConversationsHandler).Methods(http.MethodGet), scopeMessagesRead)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/messages", ListMessagesHandler).Methods(http.MethodGet), scopeMessagesRead)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/read", MarkReadHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/members", ListMembersHandler).Methods(http.MethodGet), scopeMessagesRead)
+
+	// Группы: участники, роли, название и аватар, закреплённое сообщение
+	withScope(api.HandleFunc("/groups", CreateGroupHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}", UpdateGroupHandler).Methods(http.MethodPatch), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/members", AddMembersHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/members/{userId:[0-9]+}", RemoveMemberHandler).Methods(http.MethodDelete), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/members/{userId:[0-9]+}/role", SetMemberRoleHandler).Methods(http.MethodPut), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/leave", LeaveGroupHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/pin", PinMessageHandler).Methods(http.MethodPut), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/pin", UnpinMessageHandler).Methods(http.MethodDelete), scopeMessagesWrite)
+
+	// Ссылки-приглашения в группы и заявки на вступление
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/invites", ListInvitesHandler).Methods(http.MethodGet), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/invites", CreateInviteHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/invites/{inviteId:[0-9]+}", RevokeInviteHandler).Methods(http.MethodDelete), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/join-requests", ListJoinRequestsHandler).Methods(http.MethodGet), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/join-requests/{userId:[0-9]+}/approve", ApproveJoinRequestHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/conversations/{id:[0-9]+}/join-requests/{userId:[0-9]+}", DeclineJoinRequestHandler).Methods(http.MethodDelete), scopeMessagesWrite)
+	withScope(api.HandleFunc("/invites/{token:[A-Za-z0-9_-]{1,64}}", InvitePreviewHandler).Methods(http.MethodGet), scopeMessagesRead)
+	withScope(api.HandleFunc("/invites/{token:[A-Za-z0-9_-]{1,64}}/join", JoinByInviteHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/invites/{token:[A-Za-z0-9_-]{1,64}}/request", RequestToJoinHandler).Methods(http.MethodPost), scopeMessagesWrite)
+
+	// Офлайн-синхронизация: сообщения ленты после seq и подтверждение получения устройством
+	withScope(api.HandleFunc("/sync", SyncHandler).Methods(http.MethodGet), scopeMessagesRead)
+	withScope(api.HandleFunc("/sync/ack", SyncAckHandler).Methods(http.MethodPost), scopeMessagesRead)
+
+	// Сообщения: личные (to_user_id) и в переписку (conversation_id)
+	withScope(api.HandleFunc("/messages/send", SendMessageHandler).Methods(http.MethodPost), scopeMessagesWrite)
+	withScope(api.HandleFunc("/messages/{id:[0-9]+}", EditMessageHandler).Methods(http.MethodPatch), scopeMessagesWrite)
+	withScope(api.HandleFunc("/messages/{id:[0-9]+}", DeleteMessageHandler).Methods(http.MethodDelete), scopeMessagesWrite)
+	withScope(api.HandleFunc("/messages/{id:[0-9]+}/edits", ListMessageEditsHandler).Methods(http.MethodGet), scopeMessagesRead)
+	withScope(api.HandleFunc("/messages/{id:[0-9]+}/reactions/{emoji}", AddReactionHandler).Methods(http.MethodPut), scopeMessagesWrite)
+	withScope(api.HandleFunc("/messages/{id:[0-9]+}/reactions/{emoji}", RemoveReactionHandler).Methods(http.MethodDelete), scopeMessagesWrite)
 
 	// Audio/video calls signaling
-	r.HandleFunc("/api/call/offer", CallOfferHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/call/answer", CallAnswerHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/call/candidate", CallCandidateHandler).Methods(http.MethodPost)
+	withScope(api.HandleFunc("/call/offer", CallOfferHandler).Methods(http.MethodPost), scopeCalls)
+	withScope(api.HandleFunc("/call/answer", CallAnswerHandler).Methods(http.MethodPost), scopeCalls)
+	withScope(api.HandleFunc("/call/candidate", CallCandidateHandler).Methods(http.MethodPost), scopeCalls)
+	withScope(api.HandleFunc("/call/hangup", CallHangupHandler).Methods(http.MethodPost), scopeCalls)
+
+	// Администрирование (ADMIN_USER_IDS)
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(AdminMiddleware)
+	admin.HandleFunc("/connections", ConnectionStatsHandler).Methods(http.MethodGet)
 }
 
 
@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// sessionResponse — устройство пользователя с пометкой, является ли оно текущим
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessionsHandler отдаёт активные устройства текущего пользователя
+func ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+	current := CurrentSession(r.Context())
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	sessions, err := db.ListActiveSessions(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to list sessions")
+		writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, sessionResponse{Session: s, Current: s.ID == current.ID})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeSessionHandler удалённо разлогинивает устройство: отзывает сессию,
+// её refresh-токены и закрывает открытые с неё сокеты.
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	sessionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	ok, err := db.RevokeSession(r.Context(), pool, user.ID, sessionID)
+	if err != nil {
+		log.Error().Err(err).Int64("session_id", sessionID).Msg("failed to revoke session")
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	closeSessionSockets(sessionID)
+
+	log.Info().Int64("user_id", user.ID).Int64("session_id", sessionID).Msg("session revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
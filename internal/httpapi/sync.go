@@ -0,0 +1,178 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/models"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+// Сколько сообщений ленты читаем из БД за раз при досылке
+const syncBatchSize = 500
+
+// parseSince разбирает ?since=<seq>; ok=false — параметр некорректен
+func parseSince(r *http.Request) (since int64, present, ok bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return 0, false, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, true, false
+	}
+	return n, true, true
+}
+
+// replayInbox досылает в сокет сообщения ленты после since, затем sync.done,
+// и только потом отпускает отложенные живые события (клиент на время досылки в Hold).
+func replayInbox(ctx context.Context, c *hub.Client, since int64) {
+	select {
+	case <-c.Registered():
+	case <-c.Done():
+		return
+	}
+	defer c.Resume()
+
+	pool := DB()
+	if pool == nil {
+		c.Close(websocket.CloseInternalServerErr, "sync failed")
+		return
+	}
+
+	last := since
+	for {
+		msgs, hasMore, err := db.ListInbox(ctx, pool, c.UserID, last, syncBatchSize)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", c.UserID).Int64("since", last).Msg("failed to replay inbox")
+			c.Close(websocket.CloseInternalServerErr, "sync failed")
+			return
+		}
+
+		for _, m := range msgs {
+			data, err := json.Marshal(newEvent(protocol.TypeMessageNew, "", m))
+			if err != nil || !c.SendWait(data) {
+				return
+			}
+			last = m.Seq
+		}
+
+		if !hasMore {
+			break
+		}
+	}
+
+	sendEvent(c, newEvent(protocol.TypeSyncDone, "", protocol.SyncPayload{Seq: last}))
+}
+
+// handleWSSyncAck — кадр sync.ack: устройство получило сообщения до seq
+func handleWSSyncAck(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var payload protocol.SyncPayload
+	if apiErr := decodeWSPayload(env, &payload); apiErr != nil {
+		return apiErr
+	}
+	return ackSeq(r.Context(), auth, payload.Seq)
+}
+
+// ackSeq запоминает подтверждённый seq устройства: сессии или персонального токена
+func ackSeq(ctx context.Context, auth *authInfo, seq int64) *apiError {
+	pool := DB()
+	if pool == nil {
+		return &apiError{http.StatusInternalServerError, "database not initialized"}
+	}
+
+	var err error
+	if auth.session != nil {
+		err = db.AckSessionSeq(ctx, pool, auth.session.ID, seq)
+	} else {
+		err = db.AckTokenSeq(ctx, pool, auth.pat.ID, seq)
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", auth.user.ID).Int64("seq", seq).Msg("failed to ack seq")
+		return &apiError{http.StatusInternalServerError, "failed to ack"}
+	}
+
+	// Получение хотя бы одним устройством — это доставка для отправителей
+	now := time.Now()
+	updates, err := db.MarkDelivered(ctx, pool, auth.user.ID, seq, now)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", auth.user.ID).Int64("seq", seq).Msg("failed to mark messages delivered")
+		return &apiError{http.StatusInternalServerError, "failed to ack"}
+	}
+	notifyReceipts(auth.user.ID, protocol.ReceiptDelivered, now, updates)
+
+	return nil
+}
+
+type syncResponse struct {
+	Messages []models.Message `json:"messages"`
+	LastSeq  int64            `json:"last_seq"` // последний seq пользователя; since для следующего запроса — seq последнего сообщения
+	HasMore  bool             `json:"has_more"`
+}
+
+// SyncHandler отдаёт сообщения ленты после ?since= по возрастанию seq — то же,
+// что сокет досылает при подключении, для клиентов без открытого сокета
+func SyncHandler(w http.ResponseWriter, r *http.Request) {
+	user := CurrentUser(r.Context())
+
+	since, _, ok := parseSince(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+	limit, ok := pageLimit(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	pool := DB()
+	if pool == nil {
+		writeError(w, http.StatusInternalServerError, "database not initialized")
+		return
+	}
+
+	msgs, hasMore, err := db.ListInbox(r.Context(), pool, user.ID, since, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Int64("since", since).Msg("failed to list inbox")
+		writeError(w, http.StatusInternalServerError, "failed to sync")
+		return
+	}
+	lastSeq, err := db.GetLastSeq(r.Context(), pool, user.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("failed to get last seq")
+		writeError(w, http.StatusInternalServerError, "failed to sync")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, syncResponse{Messages: msgs, LastSeq: lastSeq, HasMore: hasMore})
+}
+
+// SyncAckHandler — HTTP-вариант sync.ack: {"seq": N}
+func SyncAckHandler(w http.ResponseWriter, r *http.Request) {
+	var req protocol.SyncPayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	auth := &authInfo{user: CurrentUser(r.Context()), session: CurrentSession(r.Context()), pat: CurrentToken(r.Context())}
+	if apiErr := ackSeq(r.Context(), auth, req.Seq); apiErr != nil {
+		writeError(w, apiErr.status, apiErr.msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
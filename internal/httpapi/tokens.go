@@ -0,0 +1,147 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/models"
+)
+
+// Тип нашего JWT: access-токены нельзя путать с другими токенами, которые мы подписываем.
+const tokenTypeAccess = "access"
+
+var (
+	// Access-токен живёт недолго, продлевается через /auth/refresh
+	accessTokenTTL = 15 * time.Minute
+	// Refresh-токен живёт долго, но одноразовый: каждый обмен выдаёт новый
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func init() {
+	accessTokenTTL = envDuration("ACCESS_TOKEN_TTL", accessTokenTTL)
+	refreshTokenTTL = envDuration("REFRESH_TOKEN_TTL", refreshTokenTTL)
+}
+
+// tokenResponse — пара токенов, которую получает клиент при логине и обновлении
+type tokenResponse struct {
+	Token        string `json:"token"`         // наш access JWT
+	RefreshToken string `json:"refresh_token"` // непрозрачный refresh-токен
+	ExpiresIn    int64  `json:"expires_in"`    // время жизни access-токена в секундах
+}
+
+// issueAccessToken подписывает короткоживущий access JWT для сессии пользователя
+func issueAccessToken(user *models.User, sessionID int64) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"sid":     sessionID,
+		"typ":     tokenTypeAccess,
+		"exp":     now.Add(accessTokenTTL).Unix(),
+		"iat":     now.Unix(),
+	}
+
+	signed, err := signJWT(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign jwt: %w", err)
+	}
+
+	return signed, nil
+}
+
+// startSession регистрирует устройство, с которого пришёл запрос, и выдаёт ему
+// access JWT и refresh-токен нового семейства. Вызывается при каждом логине.
+func startSession(r *http.Request, user *models.User) (*tokenResponse, error) {
+	pool := DB()
+	if pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	session, err := db.CreateSession(r.Context(), pool, user.ID, clientName(r), truncate(r.UserAgent(), 1024), clientIP(r))
+	if err != nil {
+		return nil, err
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.CreateRefreshToken(r.Context(), pool, user.ID, session.ID, familyID, hashToken(refresh), time.Now().Add(refreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	access, err := issueAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// randomToken генерирует криптостойкую строку из n случайных байт (base64url)
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken — SHA-256 от непрозрачного токена; в БД храним только его
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// envDuration читает длительность вида "15m" из env, при ошибке оставляет значение по умолчанию
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Warn().Str("key", key).Str("value", v).Msg("invalid duration in env, using default")
+		return def
+	}
+
+	return d
+}
+
+// envInt читает положительное целое из переменной окружения
+func envInt(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Warn().Str("key", key).Str("value", v).Msg("invalid integer in env, using default")
+		return def
+	}
+
+	return n
+}
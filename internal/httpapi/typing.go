@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/db"
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/protocol"
+	"github.com/yeoboseyo/server/internal/typing"
+)
+
+const (
+	// Начало набора пересылаем участникам не чаще раза в typingThrottle
+	typingThrottle = 3 * time.Second
+	// Индикатор гаснет, если клиент не обновил его за typingTTL
+	typingTTL = 6 * time.Second
+)
+
+var (
+	// typingTracker — кто сейчас печатает; только в памяти, в БД не пишется
+	typingTracker = typing.New(typingThrottle, typingTTL, func(conversationID, userID int64, members []int64) {
+		sendTyping(conversationID, userID, false, members)
+	})
+
+	// Кадров typing от пользователя: сверх лимита клиент получает rate_limited
+	typingFramesPerUser = newRateLimiter(30, 10*time.Second)
+)
+
+// handleWSTyping — кадр typing: пересылаем онлайн-участникам переписки с троттлингом
+func handleWSTyping(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var payload protocol.TypingPayload
+	if apiErr := decodeWSPayload(env, &payload); apiErr != nil {
+		return apiErr
+	}
+
+	if !typingFramesPerUser.Allow(strconv.FormatInt(auth.user.ID, 10)) {
+		return &apiError{http.StatusTooManyRequests, "too many typing events"}
+	}
+
+	if !payload.Typing {
+		if members, ok := typingTracker.Stop(payload.ConversationID, auth.user.ID); ok {
+			sendTyping(payload.ConversationID, auth.user.ID, false, members)
+		}
+		return nil
+	}
+
+	// Уже разослали недавно — только продлеваем
+	if typingTracker.Refresh(payload.ConversationID, auth.user.ID) {
+		return nil
+	}
+
+	pool := DB()
+	if pool == nil {
+		return &apiError{http.StatusInternalServerError, "database not initialized"}
+	}
+
+	members, err := db.ListConversationMemberIDs(r.Context(), pool, payload.ConversationID)
+	if err != nil {
+		log.Error().Err(err).Int64("conversation_id", payload.ConversationID).Msg("failed to list conversation members")
+		return &apiError{http.StatusInternalServerError, "failed to send typing"}
+	}
+	if !slices.Contains(members, auth.user.ID) {
+		return &apiError{http.StatusNotFound, "conversation not found"}
+	}
+
+	others := slices.DeleteFunc(members, func(id int64) bool { return id == auth.user.ID || !wsHub.Online(id) })
+	typingTracker.Start(payload.ConversationID, auth.user.ID, others)
+	sendTyping(payload.ConversationID, auth.user.ID, true, others)
+	return nil
+}
+
+// sendTyping рассылает событие typing тем участникам, кто сейчас в сети
+func sendTyping(conversationID, userID int64, isTyping bool, members []int64) {
+	payload := protocol.TypingPayload{ConversationID: conversationID, UserID: userID, Typing: isTyping}
+	if isTyping {
+		payload.ExpiresIn = int(typingTTL / time.Second)
+	}
+	broadcast(members, newEvent(protocol.TypeTyping, "", payload), nil)
+}
@@ -0,0 +1,319 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/yeoboseyo/server/internal/hub"
+	"github.com/yeoboseyo/server/internal/protocol"
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: protocol.Subprotocols,
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// Сколько ждём первый кадр с токеном, если его не было в запросе
+const wsAuthTimeout = 10 * time.Second
+
+// Максимальная длина id экземпляра клиента (?client_id=)
+const maxClientIDLength = 64
+
+// wsConfig — параметры соединений; переопределяются через окружение (WS_*)
+var wsConfig = hub.Config{
+	SendBuffer:   256,
+	PingInterval: 25 * time.Second,
+	IdleTimeout:  60 * time.Second,
+	WriteTimeout: 10 * time.Second,
+	ReadLimit:    64 << 10,
+	IdleAfter:    presenceIdleAfter,
+}
+
+// wsHub — все открытые сокеты этого инстанса
+var wsHub *hub.Hub
+
+func init() {
+	wsConfig.PingInterval = envDuration("WS_PING_INTERVAL", wsConfig.PingInterval)
+	wsConfig.IdleTimeout = envDuration("WS_IDLE_TIMEOUT", wsConfig.IdleTimeout)
+	wsConfig.WriteTimeout = envDuration("WS_WRITE_TIMEOUT", wsConfig.WriteTimeout)
+	wsConfig.ReadLimit = envInt("WS_READ_LIMIT", wsConfig.ReadLimit)
+	if wsConfig.IdleTimeout <= wsConfig.PingInterval {
+		// Иначе живой клиент не успеет ответить на ping
+		log.Warn().Dur("ping_interval", wsConfig.PingInterval).Dur("idle_timeout", wsConfig.IdleTimeout).Msg("WS_IDLE_TIMEOUT must exceed WS_PING_INTERVAL, adjusting")
+		wsConfig.IdleTimeout = 2 * wsConfig.PingInterval
+	}
+
+	wsHub = hub.New(wsConfig)
+	wsHub.OnPresenceChange(queuePresence)
+}
+
+// wsFrameHandler обрабатывает входящий кадр одного типа. Ошибка уходит
+// клиенту кадром error со ссылкой на кадр.
+type wsFrameHandler struct {
+	scope  string // scope персонального токена, нужный для кадра
+	handle func(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError
+}
+
+// wsHandlers — кадры, которые может присылать клиент
+var wsHandlers = map[string]wsFrameHandler{
+	protocol.TypeAuth:          {"", handleWSReauth},
+	protocol.TypeMessageSend:   {scopeMessagesWrite, handleWSMessageSend},
+	protocol.TypeSyncAck:       {scopeMessagesRead, handleWSSyncAck},
+	protocol.TypeTyping:        {scopeMessagesWrite, handleWSTyping},
+	protocol.TypePresence:      {"", handleWSPresence},
+	protocol.TypeCallOffer:     {scopeCalls, handleWSCallSignal},
+	protocol.TypeCallAnswer:    {scopeCalls, handleWSCallSignal},
+	protocol.TypeCallCandidate: {scopeCalls, handleWSCallSignal},
+	protocol.TypeCallHangup:    {scopeCalls, handleWSCallSignal},
+}
+
+// MessagesWebSocketHandler открывает сокет событий. Токен — в Authorization,
+// в ?access_token= или первым кадром {"type": "auth", "payload": {"token": "..."}}.
+// Версия протокола согласуется через Sec-WebSocket-Protocol; без заголовка — yeoboseyo.v1.
+// У пользователя может быть несколько сокетов (по одному на устройство/вкладку),
+// события доставляются во все. ?client_id= — стабильный id вкладки/экземпляра
+// приложения: новое соединение с тем же id закрывает старое (4004).
+// Когда срок токена истекает, сокет закрывается (4002), если клиент
+// не прислал новый токен кадром auth.
+// С ?since=<seq> сначала досылаются сообщения ленты после seq (message.new
+// по порядку, затем sync.done), и только потом — живые события.
+func MessagesWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := r.URL.Query().Get("client_id")
+	if len(clientID) > maxClientIDLength {
+		writeError(w, http.StatusBadRequest, "client_id is too long")
+		return
+	}
+	since, syncRequested, ok := parseSince(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+
+	if offered := websocket.Subprotocols(r); len(offered) > 0 &&
+		!slices.ContainsFunc(offered, func(p string) bool { return slices.Contains(protocol.Subprotocols, p) }) {
+		writeError(w, http.StatusBadRequest, "unsupported protocol version")
+		return
+	}
+
+	var auth *authInfo
+	if raw := bearerToken(r); raw != "" {
+		var apiErr *apiError
+		auth, apiErr = authenticate(r, raw)
+		if apiErr != nil {
+			writeError(w, apiErr.status, apiErr.msg)
+			return
+		}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade сам отвечает клиенту ошибкой
+		return
+	}
+
+	conn.SetReadLimit(wsConfig.ReadLimit)
+
+	if auth == nil {
+		var closeMsg string
+		auth, closeMsg = authenticateFirstFrame(r, conn)
+		if auth == nil {
+			msg := websocket.FormatCloseMessage(hub.CloseUnauthorized, closeMsg)
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			_ = conn.Close()
+			return
+		}
+	}
+
+	var sessionID, tokenID int64
+	if auth.session != nil {
+		sessionID = auth.session.ID
+	}
+	if auth.pat != nil {
+		tokenID = auth.pat.ID
+	}
+
+	client := wsHub.NewClient(conn, auth.user.ID, sessionID, tokenID, clientID)
+	client.ExpireAt(auth.expiresAt)
+	if syncRequested {
+		client.Hold()
+		go replayInbox(r.Context(), client, since)
+	}
+	client.Run(func(c *hub.Client, data []byte) {
+		handleWSFrame(r, auth, c, data)
+	})
+}
+
+// authenticateFirstFrame ждёт кадр auth с токеном. nil — не дождались
+// или токен недействителен (второе значение — причина для кадра закрытия).
+func authenticateFirstFrame(r *http.Request, conn *websocket.Conn) (*authInfo, string) {
+	_ = conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, "missing token"
+	}
+
+	env, err := protocol.Decode(data)
+	if err != nil || env.Type != protocol.TypeAuth {
+		return nil, "missing token"
+	}
+	var payload protocol.AuthPayload
+	if err := protocol.DecodePayload(env, &payload); err != nil {
+		return nil, "missing token"
+	}
+
+	auth, apiErr := authenticate(r, payload.Token)
+	if apiErr != nil {
+		return nil, apiErr.msg
+	}
+	return auth, ""
+}
+
+// handleWSReauth — кадр auth в открытом сокете: клиент продлевает соединение
+// новым токеном того же устройства (после /auth/refresh)
+func handleWSReauth(r *http.Request, auth *authInfo, c *hub.Client, env *protocol.Envelope) *apiError {
+	var payload protocol.AuthPayload
+	if apiErr := decodeWSPayload(env, &payload); apiErr != nil {
+		return apiErr
+	}
+
+	next, apiErr := authenticate(r, payload.Token)
+	if apiErr != nil {
+		return apiErr
+	}
+	sameSession := auth.session != nil && next.session != nil && auth.session.ID == next.session.ID
+	sameToken := auth.pat != nil && next.pat != nil && auth.pat.ID == next.pat.ID
+	if !sameSession && !sameToken {
+		return &apiError{http.StatusForbidden, "token belongs to another session"}
+	}
+
+	c.ExpireAt(next.expiresAt)
+
+	var expiresAt *time.Time
+	if !next.expiresAt.IsZero() {
+		expiresAt = &next.expiresAt
+	}
+	sendEvent(c, newEvent(protocol.TypeAuthOK, env.ID, protocol.AuthOKPayload{ExpiresAt: expiresAt}))
+	return nil
+}
+
+// handleWSFrame проверяет конверт входящего кадра и передаёт его обработчику типа
+func handleWSFrame(r *http.Request, auth *authInfo, c *hub.Client, data []byte) {
+	env, err := protocol.Decode(data)
+	if err != nil {
+		sendEvent(c, protocol.Error("", protocol.CodeInvalidFrame, err.Error()))
+		return
+	}
+
+	h, ok := wsHandlers[env.Type]
+	if !ok {
+		sendEvent(c, protocol.Error(env.ID, protocol.CodeUnsupportedType, "unsupported type "+env.Type))
+		return
+	}
+	if auth.pat != nil && h.scope != "" && !auth.pat.HasScope(h.scope) {
+		sendEvent(c, protocol.Error(env.ID, protocol.CodeForbidden, "token lacks scope "+h.scope))
+		return
+	}
+
+	if apiErr := h.handle(r, auth, c, env); apiErr != nil {
+		sendEvent(c, protocol.Error(env.ID, errorCode(apiErr.status), apiErr.msg))
+	}
+}
+
+// decodeWSPayload разбирает и проверяет payload кадра
+func decodeWSPayload(env *protocol.Envelope, v protocol.Validator) *apiError {
+	if err := protocol.DecodePayload(env, v); err != nil {
+		return &apiError{http.StatusBadRequest, err.Error()}
+	}
+	return nil
+}
+
+// errorCode переводит HTTP-статус ошибки в код кадра error
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return protocol.CodeInvalidPayload
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return protocol.CodeForbidden
+	case http.StatusNotFound:
+		return protocol.CodeNotFound
+	case http.StatusConflict:
+		return protocol.CodeUnavailable
+	case http.StatusTooManyRequests:
+		return protocol.CodeRateLimited
+	default:
+		return protocol.CodeInternal
+	}
+}
+
+// newEvent собирает кадр сервера; ошибка возможна только при сериализации payload
+func newEvent(typ, ref string, payload any) *protocol.Envelope {
+	env, err := protocol.New(typ, payload)
+	if err != nil {
+		log.Error().Err(err).Str("type", typ).Msg("failed to marshal ws payload")
+		return nil
+	}
+	env.Ref = ref
+	return env
+}
+
+// broadcast доставляет событие во все сокеты пользователей, кроме origin
+func broadcast(userIDs []int64, env *protocol.Envelope, origin *hub.Client) {
+	if env == nil {
+		return
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Error().Err(err).Str("type", env.Type).Msg("failed to marshal ws event")
+		return
+	}
+
+	for _, id := range userIDs {
+		wsHub.SendToUser(id, data, origin)
+	}
+}
+
+// sendEvent отправляет событие в одно соединение
+func sendEvent(c *hub.Client, env *protocol.Envelope) {
+	if env == nil {
+		return
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Error().Err(err).Str("type", env.Type).Msg("failed to marshal ws event")
+		return
+	}
+	c.Send(data)
+}
+
+// CloseSockets закрывает все сокеты с кодом 1001 — при остановке сервера,
+// чтобы клиенты сразу переподключились к другому инстансу
+func CloseSockets() {
+	wsHub.Shutdown()
+}
+
+// closeSessionSockets закрывает сокеты отозванной сессии
+func closeSessionSockets(sessionID int64) {
+	wsHub.CloseSession(sessionID, hub.CloseSessionRevoked, "session revoked")
+}
+
+// ProtocolSchemaHandler отдаёт JSON Schema версии протокола сокета
+// для генерации типизированных клиентов
+func ProtocolSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	schema := protocol.Schema(mux.Vars(r)["version"])
+	if schema == nil {
+		writeError(w, http.StatusNotFound, "unknown protocol version")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(schema)
+}
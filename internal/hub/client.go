@@ -0,0 +1,274 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Сколько ждём отправки кадра закрытия
+const closeWriteTimeout = time.Second
+
+// Client — одно WebSocket-соединение. Писать в сокет может только writePump,
+// остальные ставят кадры в буфер через Send.
+type Client struct {
+	UserID    int64
+	SessionID int64  // 0 — соединение по персональному токену
+	TokenID   int64  // персональный токен (0 — интерактивная сессия)
+	Instance  string // id экземпляра клиента (вкладки/приложения); "" — без замены соединений
+
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+
+	expiryMu sync.Mutex
+	expiry   *time.Timer
+
+	// Активность для статуса присутствия (под hub.mu)
+	reportedIdle bool      // клиент сам сообщил, что неактивен (приложение свёрнуто)
+	autoIdle     bool      // клиент давно ничего не присылал
+	lastActive   time.Time // когда пришёл последний кадр
+
+	// Пока holding, события копятся в held (см. Hold)
+	holdMu  sync.Mutex
+	holding bool
+	held    [][]byte
+
+	registered chan struct{}
+	closeOnce  sync.Once
+	done       chan struct{}
+}
+
+// NewClient оборачивает соединение; зарегистрировать его в хабе нужно отдельно (Hub.Register)
+func (h *Hub) NewClient(conn *websocket.Conn, userID, sessionID, tokenID int64, instance string) *Client {
+	return &Client{
+		UserID:     userID,
+		SessionID:  sessionID,
+		TokenID:    tokenID,
+		Instance:   instance,
+		hub:        h,
+		conn:       conn,
+		lastActive: time.Now(),
+		send:       make(chan []byte, h.cfg.SendBuffer),
+		registered: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Send ставит кадр в очередь на отправку, не блокируясь. Если буфер переполнен,
+// клиент не успевает читать — закрываем его, чтобы он не тормозил остальных.
+func (c *Client) Send(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	c.holdMu.Lock()
+	defer c.holdMu.Unlock()
+	if c.holding {
+		if len(c.held) >= c.hub.cfg.SendBuffer {
+			c.Close(CloseSlowConsumer, "slow consumer")
+			return false
+		}
+		c.held = append(c.held, data)
+		return true
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	default:
+		c.Close(CloseSlowConsumer, "slow consumer")
+		return false
+	}
+}
+
+// SendWait ставит кадр в очередь, дожидаясь места в буфере (для досылки
+// истории, которая может быть больше буфера). false — соединение закрыто.
+func (c *Client) SendWait(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// Hold откладывает события, пришедшие через Send, до Resume: так досылаемая
+// история уходит клиенту раньше живых событий. Вызывать до Run.
+func (c *Client) Hold() {
+	c.holdMu.Lock()
+	defer c.holdMu.Unlock()
+	c.holding = true
+}
+
+// Resume отправляет отложенные события и возвращает обычную доставку.
+// Отложенное забирается под holdMu, а ставится в очередь без него: Send других
+// горутин (рассылка по пользователям) не ждёт досылки и, пока она идёт, тоже
+// откладывает события, чтобы не обогнать уже отложенные.
+func (c *Client) Resume() {
+	for {
+		c.holdMu.Lock()
+		held := c.held
+		c.held = nil
+		if len(held) == 0 {
+			c.holding = false
+			c.holdMu.Unlock()
+			return
+		}
+		c.holdMu.Unlock()
+
+		for _, data := range held {
+			if !c.SendWait(data) {
+				// Соединение закрыто — досылать больше некому
+				c.holdMu.Lock()
+				c.held = nil
+				c.holding = false
+				c.holdMu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// SetIdle запоминает, что клиент сам сообщил о (не)активности
+func (c *Client) SetIdle(idle bool) {
+	c.updateActivity(func() { c.reportedIdle = idle })
+}
+
+// touch отмечает входящий кадр: соединение снова активно
+func (c *Client) touch() {
+	c.updateActivity(func() {
+		c.lastActive = time.Now()
+		c.autoIdle = false
+	})
+}
+
+// checkIdle помечает соединение неактивным, если кадров не было дольше IdleAfter (0 — не помечать)
+func (c *Client) checkIdle() {
+	if c.hub.cfg.IdleAfter <= 0 {
+		return
+	}
+	c.updateActivity(func() {
+		if time.Since(c.lastActive) >= c.hub.cfg.IdleAfter {
+			c.autoIdle = true
+		}
+	})
+}
+
+func (c *Client) idle() bool {
+	return c.reportedIdle || c.autoIdle
+}
+
+// updateActivity меняет активность соединения и сообщает хабу, если статус пользователя изменился
+func (c *Client) updateActivity(fn func()) {
+	h := c.hub
+	h.mu.Lock()
+	before := h.status(c.UserID)
+	fn()
+	after := h.status(c.UserID)
+	h.mu.Unlock()
+
+	h.presenceChanged(c.UserID, before, after)
+}
+
+// Registered закрывается, когда соединение попало в реестр хаба и ему доставляются события
+func (c *Client) Registered() <-chan struct{} {
+	return c.registered
+}
+
+// ExpireAt закрывает соединение с CloseAuthExpired в момент t (срок токена).
+// Повторный вызов переносит срок; нулевое t — без срока.
+func (c *Client) ExpireAt(t time.Time) {
+	c.expiryMu.Lock()
+	defer c.expiryMu.Unlock()
+
+	if c.expiry != nil {
+		c.expiry.Stop()
+		c.expiry = nil
+	}
+	if t.IsZero() {
+		return
+	}
+	c.expiry = time.AfterFunc(time.Until(t), func() {
+		c.Close(CloseAuthExpired, "token expired")
+	})
+}
+
+// Close закрывает соединение с кодом и причиной (безопасно вызывать повторно и из любых горутин)
+func (c *Client) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ExpireAt(time.Time{})
+		// WriteControl и Close безопасно вызывать параллельно с записью в writePump
+		msg := websocket.FormatCloseMessage(code, reason)
+		_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteTimeout))
+		_ = c.conn.Close()
+	})
+}
+
+// Done закрывается, когда соединение закрыто
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Run регистрирует соединение, запускает запись в отдельной горутине и читает
+// входящие кадры, передавая их в handle, пока соединение не закроется.
+// Соединение без входящих кадров (включая pong) дольше IdleTimeout считается мёртвым;
+// кадр больше ReadLimit закрывает соединение с 1009.
+func (c *Client) Run(handle func(c *Client, data []byte)) {
+	if !c.hub.Register(c) {
+		return
+	}
+	defer c.hub.Unregister(c)
+	close(c.registered)
+
+	go c.writePump()
+	defer c.Close(websocket.CloseNormalClosure, "")
+
+	cfg := c.hub.cfg
+	c.conn.SetReadLimit(cfg.ReadLimit)
+	_ = c.conn.SetReadDeadline(time.Now().Add(cfg.IdleTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(cfg.IdleTimeout))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(cfg.IdleTimeout))
+		c.touch()
+		handle(c, data)
+	}
+}
+
+// writePump — единственный писатель в сокет; заодно шлёт ping
+func (c *Client) writePump() {
+	cfg := c.hub.cfg
+	ping := time.NewTicker(cfg.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.Close(websocket.CloseInternalServerErr, "write failed")
+				return
+			}
+		case <-ping.C:
+			c.checkIdle()
+			_ = c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close(websocket.CloseInternalServerErr, "write failed")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func testConfig() Config {
+	return Config{
+		SendBuffer:   16,
+		PingInterval: time.Hour,
+		IdleTimeout:  time.Hour,
+		WriteTimeout: time.Second,
+		ReadLimit:    1 << 16,
+	}
+}
+
+// testConn отдаёт серверную и клиентскую стороны настоящего WebSocket-соединения
+func testConn(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return <-conns, client
+}
+
+func readFrames(t *testing.T, conn *websocket.Conn, n int) []string {
+	t.Helper()
+	frames := make([]string, 0, n)
+	for range n {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read frame %d: %v", len(frames), err)
+		}
+		frames = append(frames, string(data))
+	}
+	return frames
+}
+
+// Досылка истории уходит клиенту раньше живых событий, пришедших во время неё
+func TestReplayBeforeLiveEvents(t *testing.T) {
+	h := New(testConfig())
+	server, client := testConn(t)
+
+	c := h.NewClient(server, 1, 10, 0, "")
+	c.Hold()
+	go c.Run(func(*Client, []byte) {})
+	<-c.Registered()
+
+	h.SendToUser(1, []byte("live-1"), nil)
+	for _, data := range []string{"history-1", "history-2"} {
+		if !c.SendWait([]byte(data)) {
+			t.Fatal("SendWait failed")
+		}
+	}
+	h.SendToUser(1, []byte("live-2"), nil)
+	c.Resume()
+	h.SendToUser(1, []byte("live-3"), nil)
+
+	got := strings.Join(readFrames(t, client, 5), ",")
+	if want := "history-1,history-2,live-1,live-2,live-3"; got != want {
+		t.Fatalf("frames: got %s, want %s", got, want)
+	}
+}
+
+// Пока Resume ждёт места в буфере, Send других горутин не блокируется
+// и не обгоняет отложенные события
+func TestResumeDoesNotBlockSenders(t *testing.T) {
+	cfg := testConfig()
+	cfg.SendBuffer = 3
+	h := New(cfg)
+	// writePump не запущен: кадры остаются в c.send, и Resume упрётся в полный буфер
+	c := h.NewClient(nil, 1, 10, 0, "")
+	c.Hold()
+
+	c.SendWait([]byte("history-1"))
+	c.SendWait([]byte("history-2"))
+	c.SendWait([]byte("history-3"))
+	c.Send([]byte("held-1"))
+	c.Send([]byte("held-2"))
+
+	resumed := make(chan struct{})
+	go func() {
+		c.Resume()
+		close(resumed)
+	}()
+
+	// Ждём, пока Resume заберёт отложенное и встанет на полном буфере
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		c.holdMu.Lock()
+		taken := len(c.held) == 0
+		c.holdMu.Unlock()
+		if taken {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Resume did not take held events")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	sent := make(chan bool, 1)
+	go func() { sent <- c.Send([]byte("live")) }()
+	select {
+	case ok := <-sent:
+		if !ok {
+			t.Fatal("Send during Resume failed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked while Resume was flushing held events")
+	}
+
+	var got []string
+	for len(got) < 6 {
+		select {
+		case data := <-c.send:
+			got = append(got, string(data))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	<-resumed
+
+	if s := strings.Join(got, ","); s != "history-1,history-2,history-3,held-1,held-2,live" {
+		t.Fatalf("frames: got %s", s)
+	}
+
+	// После Resume доставка снова напрямую
+	c.Send([]byte("after"))
+	if data := <-c.send; string(data) != "after" {
+		t.Fatalf("unexpected frame %s", data)
+	}
+}
+
+// Переполнение буфера закрывает соединение кодом slow consumer
+func TestSlowConsumerClosed(t *testing.T) {
+	cfg := testConfig()
+	cfg.SendBuffer = 1
+	h := New(cfg)
+	server, client := testConn(t)
+
+	// writePump не запущен, поэтому второй кадр не помещается в буфер
+	c := h.NewClient(server, 1, 10, 0, "")
+	if !c.Send([]byte("first")) {
+		t.Fatal("first Send failed")
+	}
+	if c.Send([]byte("second")) {
+		t.Fatal("expected overflowing Send to fail")
+	}
+
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("expected client to be closed")
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := client.ReadMessage()
+	if !websocket.IsCloseError(err, CloseSlowConsumer) {
+		t.Fatalf("expected close %d, got %v", CloseSlowConsumer, err)
+	}
+}
+
+// Новое соединение того же экземпляра клиента вытесняет прежнее
+func TestRegisterReplacesInstance(t *testing.T) {
+	h := New(testConfig())
+	firstServer, firstClient := testConn(t)
+	secondServer, _ := testConn(t)
+
+	first := h.NewClient(firstServer, 1, 10, 0, "tab-1")
+	go first.Run(func(*Client, []byte) {})
+	<-first.Registered()
+
+	second := h.NewClient(secondServer, 1, 10, 0, "tab-1")
+	go second.Run(func(*Client, []byte) {})
+	<-second.Registered()
+
+	_ = firstClient.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := firstClient.ReadMessage()
+	if !websocket.IsCloseError(err, CloseReplaced) {
+		t.Fatalf("expected close %d, got %v", CloseReplaced, err)
+	}
+
+	// Вытесненное соединение уходит из реестра, когда завершится его Run
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		conns, users := h.Stats()
+		if conns == 1 && users == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 connection of 1 user, got %d/%d", conns, users)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,252 @@
+// Package hub держит открытые WebSocket-соединения и доставляет события
+// нужным пользователям. Состояние живёт в памяти одного инстанса сервера.
+package hub
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Коды закрытия сокета (4000–4999 — коды приложения)
+const (
+	CloseUnauthorized   = 4001 // токен не передан или недействителен
+	CloseAuthExpired    = 4002 // срок токена истёк, а новый не прислали (кадр auth)
+	CloseSessionRevoked = 4003 // сессия устройства отозвана
+	CloseReplaced       = 4004 // тот же экземпляр клиента подключился заново
+	CloseSlowConsumer   = 4008 // клиент не успевает читать, буфер отправки переполнен
+
+	CloseShutdown = websocket.CloseGoingAway // сервер останавливается, нужно переподключиться
+)
+
+// Статусы присутствия пользователя
+const (
+	StatusOnline  = "online"  // есть активное соединение
+	StatusIdle    = "idle"    // соединения есть, но все неактивны
+	StatusOffline = "offline" // соединений нет
+)
+
+// Config — параметры соединений
+type Config struct {
+	SendBuffer   int           // сколько исходящих кадров может ждать отправки, прежде чем клиент признан медленным
+	PingInterval time.Duration // как часто слать ping
+	IdleTimeout  time.Duration // сколько ждать любой кадр (в т.ч. pong), прежде чем считать соединение мёртвым
+	WriteTimeout time.Duration // сколько ждать записи одного кадра
+	ReadLimit    int64         // максимальный размер входящего кадра в байтах
+	IdleAfter    time.Duration // через сколько без входящих кадров соединение считается неактивным (0 — никогда)
+}
+
+// Hub — реестр соединений по пользователю, сессии и персональному токену
+type Hub struct {
+	cfg Config
+
+	mu         sync.RWMutex
+	closed     bool
+	byUser     map[int64]map[*Client]struct{}
+	bySession  map[int64]map[*Client]struct{}
+	byToken    map[int64]map[*Client]struct{}
+	byInstance map[string]*Client
+
+	onPresence func(userID int64)
+}
+
+// New создаёт хаб
+func New(cfg Config) *Hub {
+	return &Hub{
+		cfg:        cfg,
+		byUser:     make(map[int64]map[*Client]struct{}),
+		bySession:  make(map[int64]map[*Client]struct{}),
+		byToken:    make(map[int64]map[*Client]struct{}),
+		byInstance: make(map[string]*Client),
+	}
+}
+
+// OnPresenceChange задаёт, кого звать, когда статус пользователя мог измениться
+// (см. Status). Вызывается без блокировок хаба; задавать до первых соединений.
+func (h *Hub) OnPresenceChange(fn func(userID int64)) {
+	h.onPresence = fn
+}
+
+// Status — статус присутствия пользователя по его соединениям
+func (h *Hub) Status(userID int64) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status(userID)
+}
+
+func (h *Hub) status(userID int64) string {
+	set := h.byUser[userID]
+	if len(set) == 0 {
+		return StatusOffline
+	}
+	for c := range set {
+		if !c.idle() {
+			return StatusOnline
+		}
+	}
+	return StatusIdle
+}
+
+// presenceChanged сообщает подписчику, если статус пользователя изменился
+func (h *Hub) presenceChanged(userID int64, before, after string) {
+	if before != after && h.onPresence != nil {
+		h.onPresence(userID)
+	}
+}
+
+// Register добавляет соединение в реестр; после этого ему доставляются события.
+// Прежнее соединение того же экземпляра клиента закрывается с CloseReplaced.
+// После Shutdown новые соединения сразу закрываются (false).
+func (h *Hub) Register(c *Client) bool {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		c.Close(CloseShutdown, "server shutdown")
+		return false
+	}
+
+	before := h.status(c.UserID)
+	addTo(h.byUser, c.UserID, c)
+	if c.SessionID != 0 {
+		addTo(h.bySession, c.SessionID, c)
+	}
+	if c.TokenID != 0 {
+		addTo(h.byToken, c.TokenID, c)
+	}
+	var replaced *Client
+	if c.Instance != "" {
+		key := c.instanceKey()
+		replaced = h.byInstance[key]
+		h.byInstance[key] = c
+	}
+	after := h.status(c.UserID)
+	h.mu.Unlock()
+
+	if replaced != nil {
+		replaced.Close(CloseReplaced, "replaced by a new connection")
+	}
+	h.presenceChanged(c.UserID, before, after)
+	return true
+}
+
+// Unregister убирает соединение из реестра
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	before := h.status(c.UserID)
+	removeFrom(h.byUser, c.UserID, c)
+	if c.SessionID != 0 {
+		removeFrom(h.bySession, c.SessionID, c)
+	}
+	if c.TokenID != 0 {
+		removeFrom(h.byToken, c.TokenID, c)
+	}
+	if c.Instance != "" && h.byInstance[c.instanceKey()] == c {
+		delete(h.byInstance, c.instanceKey())
+	}
+	after := h.status(c.UserID)
+	h.mu.Unlock()
+
+	h.presenceChanged(c.UserID, before, after)
+}
+
+// SendToUser ставит кадр в очередь всем соединениям пользователя, кроме except
+// (соединения, с которого пришло событие; может быть nil)
+func (h *Hub) SendToUser(userID int64, data []byte, except *Client) {
+	for _, c := range h.clients(h.byUser, userID) {
+		if c != except {
+			c.Send(data)
+		}
+	}
+}
+
+// Online сообщает, есть ли у пользователя хотя бы одно соединение
+func (h *Hub) Online(userID int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byUser[userID]) > 0
+}
+
+// Stats — число открытых соединений и пользователей с ними
+func (h *Hub) Stats() (connections, users int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, set := range h.byUser {
+		connections += len(set)
+	}
+	return connections, len(h.byUser)
+}
+
+// CloseSession закрывает все соединения сессии (логаут, удаление устройства)
+func (h *Hub) CloseSession(sessionID int64, code int, reason string) {
+	for _, c := range h.clients(h.bySession, sessionID) {
+		c.Close(code, reason)
+	}
+}
+
+// CloseToken закрывает все соединения, открытые по персональному токену
+func (h *Hub) CloseToken(tokenID int64, code int, reason string) {
+	for _, c := range h.clients(h.byToken, tokenID) {
+		c.Close(code, reason)
+	}
+}
+
+// CloseUser закрывает все соединения пользователя
+func (h *Hub) CloseUser(userID int64, code int, reason string) {
+	for _, c := range h.clients(h.byUser, userID) {
+		c.Close(code, reason)
+	}
+}
+
+// Shutdown закрывает все соединения с CloseShutdown и перестаёт принимать новые
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	h.closed = true
+	var all []*Client
+	for _, set := range h.byUser {
+		for c := range set {
+			all = append(all, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range all {
+		c.Close(CloseShutdown, "server shutdown")
+	}
+}
+
+// clients — снимок соединений по ключу, чтобы отправлять без удержания блокировки
+func (h *Hub) clients(index map[int64]map[*Client]struct{}, key int64) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	set := index[key]
+	list := make([]*Client, 0, len(set))
+	for c := range set {
+		list = append(list, c)
+	}
+	return list
+}
+
+func (c *Client) instanceKey() string {
+	return strconv.FormatInt(c.UserID, 10) + ":" + c.Instance
+}
+
+func addTo(index map[int64]map[*Client]struct{}, key int64, c *Client) {
+	set, ok := index[key]
+	if !ok {
+		set = make(map[*Client]struct{})
+		index[key] = set
+	}
+	set[c] = struct{}{}
+}
+
+func removeFrom(index map[int64]map[*Client]struct{}, key int64, c *Client) {
+	set := index[key]
+	delete(set, c)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
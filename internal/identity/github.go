@@ -0,0 +1,138 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// GitHubConfig — настройки OAuth App / GitHub App для входа через GitHub
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// APIURL — базовый URL REST API, для GitHub Enterprise переопределяется
+	APIURL string
+}
+
+// GitHubProvider — вход через GitHub OAuth2. GitHub не OIDC: id_token нет,
+// профиль и подтверждённый email берём из REST API.
+type GitHubProvider struct {
+	oauth  *oauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+func NewGitHub(cfg GitHubConfig) *GitHubProvider {
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+
+	return &GitHubProvider{
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+		apiURL: apiURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *GitHubProvider) Name() string        { return "github" }
+func (p *GitHubProvider) RedirectURL() string { return p.oauth.RedirectURL }
+func (p *GitHubProvider) FormPost() bool      { return false }
+
+// AuthCodeURL строит ссылку на логин; nonce у GitHub нет, защищаемся state и PKCE
+func (p *GitHubProvider) AuthCodeURL(_ context.Context, state, _, verifier string) (string, error) {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange обменивает код на access token и загружает профиль и основной подтверждённый email
+func (p *GitHubProvider) Exchange(ctx context.Context, code, verifier, _ string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.get(ctx, token.AccessToken, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user has no id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, token.AccessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	ident := &Identity{
+		Provider: p.Name(),
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Picture:  user.AvatarURL,
+	}
+	if ident.Name == "" {
+		ident.Name = user.Login
+	}
+
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			ident.Email = e.Email
+			ident.EmailVerified = true
+			break
+		}
+	}
+
+	return ident, nil
+}
+
+func (p *GitHubProvider) get(ctx context.Context, accessToken, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("github %s status %d: %s", path, resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("github %s: failed to decode: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProvider возвращается, если провайдер с таким именем не настроен
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Identity — проверенная личность пользователя у внешнего провайдера
+type Identity struct {
+	Provider      string // имя провайдера: google, github, apple, keycloak...
+	Subject       string // стабильный ID пользователя у провайдера
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// Provider — внешний провайдер входа через OAuth2 authorization code flow
+type Provider interface {
+	// Name — имя провайдера, оно же сегмент пути /auth/{provider}/...
+	Name() string
+	// RedirectURL — наш callback, зарегистрированный у провайдера
+	RedirectURL() string
+	// FormPost — провайдер возвращает код POST-запросом (response_mode=form_post, как Apple)
+	FormPost() bool
+	// AuthCodeURL строит ссылку на страницу логина провайдера
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange обменивает код на токены и возвращает проверенную личность
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// IDTokenVerifier реализуют провайдеры, у которых фронтенд может сам получить id_token
+// и прислать его нам (POST /auth/{provider}/frontend).
+type IDTokenVerifier interface {
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Identity, error)
+}
+
+// Registry — набор настроенных провайдеров по имени
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register добавляет провайдера (с тем же именем — заменяет)
+func (r *Registry) Register(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.Name()] = p
+}
+
+// Get возвращает провайдера по имени
+func (r *Registry) Get(name string) (Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names возвращает имена настроенных провайдеров в алфавитном порядке
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
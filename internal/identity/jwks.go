@@ -0,0 +1,212 @@
+package identity
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// Если провайдер не прислал Cache-Control, держим ключи час
+	jwksDefaultTTL = time.Hour
+	// Не чаще этого перезапрашиваем JWKS из-за неизвестного kid (защита от флуда поддельными токенами)
+	jwksMinRefetch = 30 * time.Second
+	// Нижняя граница TTL: no-cache/no-store и крошечный max-age не должны означать запрос на каждый логин
+	jwksMinTTL = 5 * time.Minute
+	// После неудачной загрузки столько не ходим к провайдеру снова
+	jwksRetryBackoff = 15 * time.Second
+)
+
+// jwksCache хранит публичные RSA-ключи провайдера (JWKS) и обновляет их
+// по истечении Cache-Control max-age или при встрече неизвестного kid.
+type jwksCache struct {
+	url    string
+	client *http.Client
+	// Параллельные логины ждут одну загрузку JWKS, а не выстраиваются в очередь
+	fetch singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time // время последней попытки загрузки
+	lastErr   error     // ошибка последней попытки; до retryAt повторов нет
+	retryAt   time.Time
+}
+
+func newJWKSCache(url string, client *http.Client) *jwksCache {
+	return &jwksCache{
+		url:    url,
+		client: client,
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+// key возвращает публичный ключ по kid, при необходимости перезагружая JWKS.
+// Сетевой запрос идёт без c.mu, чтобы медленный провайдер не блокировал кеш.
+func (c *jwksCache) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	now := time.Now()
+	key, known := c.keys[kid]
+	stale := now.After(c.expiresAt)
+	unknownKid := !known && now.Sub(c.fetchedAt) > jwksMinRefetch
+	backoff := now.Before(c.retryAt)
+	lastErr := c.lastErr
+	c.mu.Unlock()
+
+	if (stale || unknownKid) && !backoff {
+		// Отмена запроса одного логина не должна обрывать загрузку, которую ждут остальные
+		_, err, _ := c.fetch.Do("jwks", func() (any, error) {
+			return nil, c.refresh(context.WithoutCancel(ctx))
+		})
+		if err != nil {
+			// Провайдер недоступен — продолжаем работать на последних известных ключах
+			if known {
+				log.Warn().Err(err).Str("url", c.url).Msg("failed to refresh jwks, using cached keys")
+				return key, nil
+			}
+			return nil, err
+		}
+
+		c.mu.Lock()
+		key, known = c.keys[kid]
+		c.mu.Unlock()
+	} else if !known && backoff && lastErr != nil {
+		return nil, fmt.Errorf("jwks unavailable: %w", lastErr)
+	}
+
+	if !known {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+// refresh скачивает JWKS и обновляет кеш; неудача включает паузу перед следующей попыткой
+func (c *jwksCache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	keys, ttl, err := c.download(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.lastErr = err
+		c.retryAt = time.Now().Add(jwksRetryBackoff)
+		return err
+	}
+
+	c.keys = keys
+	c.expiresAt = time.Now().Add(ttl)
+	c.lastErr = nil
+	c.retryAt = time.Time{}
+
+	return nil
+}
+
+// download запрашивает JWKS у провайдера и возвращает ключи и срок их жизни
+func (c *jwksCache) download(ctx context.Context) (map[string]*rsa.PublicKey, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, fmt.Errorf("jwks status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Kid == "" {
+			continue
+		}
+		pub, err := rsaPublicKey(k.N, k.E)
+		if err != nil {
+			log.Warn().Err(err).Str("kid", k.Kid).Msg("skipping invalid jwks key")
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	if len(keys) == 0 {
+		return nil, 0, errors.New("jwks contains no usable RSA keys")
+	}
+
+	return keys, cacheMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// cacheMaxAge разбирает Cache-Control; no-store/no-cache и max-age меньше jwksMinTTL
+// поднимаются до jwksMinTTL
+func cacheMaxAge(header string) time.Duration {
+	if header == "" {
+		return jwksDefaultTTL
+	}
+
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return jwksMinTTL
+		case strings.HasPrefix(directive, "max-age="):
+			secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil || secs < 0 {
+				return jwksDefaultTTL
+			}
+			return max(time.Duration(secs)*time.Second, jwksMinTTL)
+		}
+	}
+
+	return jwksDefaultTTL
+}
+
+func rsaPublicKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("unsupported exponent")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
@@ -0,0 +1,358 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeJWKS — поддельный JWKS-эндпоинт провайдера с подменяемым набором ключей
+type fakeJWKS struct {
+	srv  *httptest.Server
+	hits atomic.Int32
+
+	mu           sync.Mutex
+	keys         map[string]*rsa.PrivateKey
+	cacheControl string
+	status       int
+}
+
+func newFakeJWKS(t *testing.T) *fakeJWKS {
+	t.Helper()
+	f := &fakeJWKS{keys: make(map[string]*rsa.PrivateKey), status: http.StatusOK}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.hits.Add(1)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if f.status != http.StatusOK {
+			http.Error(w, "unavailable", f.status)
+			return
+		}
+
+		type jwk struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		}
+		set := struct {
+			Keys []jwk `json:"keys"`
+		}{Keys: []jwk{}}
+		for kid, k := range f.keys {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "RSA",
+				Kid: kid,
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+
+		if f.cacheControl != "" {
+			w.Header().Set("Cache-Control", f.cacheControl)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// rotate заменяет набор ключей провайдера на новые kid
+func (f *fakeJWKS) rotate(t *testing.T, kids ...string) map[string]*rsa.PrivateKey {
+	t.Helper()
+	keys := make(map[string]*rsa.PrivateKey, len(kids))
+	for _, kid := range kids {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+		keys[kid] = k
+	}
+
+	f.mu.Lock()
+	f.keys = keys
+	f.mu.Unlock()
+	return keys
+}
+
+func (f *fakeJWKS) setStatus(status int) {
+	f.mu.Lock()
+	f.status = status
+	f.mu.Unlock()
+}
+
+func (f *fakeJWKS) cache() *jwksCache {
+	return newJWKSCache(f.srv.URL, f.srv.Client())
+}
+
+func TestJWKSCacheTTL(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.cacheControl = "public, max-age=3600"
+	keys := f.rotate(t, "a")
+	c := f.cache()
+	ctx := context.Background()
+
+	for range 3 {
+		got, err := c.key(ctx, "a")
+		if err != nil {
+			t.Fatalf("key: %v", err)
+		}
+		if got.N.Cmp(keys["a"].N) != 0 {
+			t.Fatal("key does not match the published one")
+		}
+	}
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("expected 1 fetch within max-age, got %d", n)
+	}
+	if ttl := time.Until(c.expiresAt); ttl < 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("expected ttl from max-age, got %s", ttl)
+	}
+
+	// По истечении TTL ключи перезапрашиваются
+	c.mu.Lock()
+	c.expiresAt = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+	if _, err := c.key(ctx, "a"); err != nil {
+		t.Fatalf("key after expiry: %v", err)
+	}
+	if n := f.hits.Load(); n != 2 {
+		t.Fatalf("expected refetch after expiry, got %d fetches", n)
+	}
+}
+
+func TestJWKSCacheNoCacheFloor(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.cacheControl = "no-cache"
+	f.rotate(t, "a")
+	c := f.cache()
+
+	for range 3 {
+		if _, err := c.key(context.Background(), "a"); err != nil {
+			t.Fatalf("key: %v", err)
+		}
+	}
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("no-cache must not refetch on every login, got %d fetches", n)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":                     jwksDefaultTTL,
+		"no-store":             jwksMinTTL,
+		"No-Cache, max-age=60": jwksMinTTL,
+		"max-age=0":            jwksMinTTL,
+		"public, max-age=7200": 2 * time.Hour,
+		"max-age=abc":          jwksDefaultTTL,
+		"max-age=-5":           jwksDefaultTTL,
+		"private":              jwksDefaultTTL,
+	}
+	for header, want := range cases {
+		if got := cacheMaxAge(header); got != want {
+			t.Errorf("cacheMaxAge(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestJWKSCacheRotation(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.rotate(t, "old")
+	c := f.cache()
+	ctx := context.Background()
+
+	if _, err := c.key(ctx, "old"); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+
+	newKeys := f.rotate(t, "new")
+
+	// Сразу после загрузки неизвестный kid не вызывает повторный запрос
+	if _, err := c.key(ctx, "new"); err == nil {
+		t.Fatal("expected unknown kid within refetch interval")
+	}
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("expected no refetch within %s, got %d fetches", jwksMinRefetch, n)
+	}
+
+	c.mu.Lock()
+	c.fetchedAt = time.Now().Add(-jwksMinRefetch - time.Second)
+	c.mu.Unlock()
+
+	got, err := c.key(ctx, "new")
+	if err != nil {
+		t.Fatalf("key after rotation: %v", err)
+	}
+	if got.N.Cmp(newKeys["new"].N) != 0 {
+		t.Fatal("rotated key does not match the published one")
+	}
+	if n := f.hits.Load(); n != 2 {
+		t.Fatalf("expected refetch for rotated kid, got %d fetches", n)
+	}
+
+	// Снятый с публикации ключ больше не принимается
+	if _, err := c.key(ctx, "old"); err == nil {
+		t.Fatal("expected retired kid to be rejected")
+	}
+}
+
+func TestJWKSCacheUnknownKid(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.rotate(t, "a")
+	c := f.cache()
+
+	for range 5 {
+		_, err := c.key(context.Background(), "forged")
+		if err == nil || !strings.Contains(err.Error(), "unknown key id") {
+			t.Fatalf("expected unknown key id, got %v", err)
+		}
+	}
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("unknown kid must not refetch on every token, got %d fetches", n)
+	}
+}
+
+func TestJWKSCacheBackoff(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.rotate(t, "a")
+	f.setStatus(http.StatusServiceUnavailable)
+	c := f.cache()
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := c.key(ctx, "a"); err == nil {
+			t.Fatal("expected error while provider is down")
+		}
+	}
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("expected single fetch during backoff, got %d", n)
+	}
+
+	f.setStatus(http.StatusOK)
+	c.mu.Lock()
+	c.retryAt = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+
+	if _, err := c.key(ctx, "a"); err != nil {
+		t.Fatalf("key after backoff: %v", err)
+	}
+	if n := f.hits.Load(); n != 2 {
+		t.Fatalf("expected retry after backoff, got %d fetches", n)
+	}
+}
+
+func TestJWKSCacheKeepsKeysOnFailedRefresh(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.rotate(t, "a")
+	c := f.cache()
+	ctx := context.Background()
+
+	if _, err := c.key(ctx, "a"); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+
+	f.setStatus(http.StatusInternalServerError)
+	c.mu.Lock()
+	c.expiresAt = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+
+	for range 3 {
+		if _, err := c.key(ctx, "a"); err != nil {
+			t.Fatalf("expected cached key while provider is down: %v", err)
+		}
+	}
+	if n := f.hits.Load(); n != 2 {
+		t.Fatalf("expected one failed refresh followed by backoff, got %d fetches", n)
+	}
+}
+
+func TestJWKSCacheConcurrentFetch(t *testing.T) {
+	f := newFakeJWKS(t)
+	f.rotate(t, "a")
+	c := f.cache()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if _, err := c.key(context.Background(), "a"); err != nil {
+				t.Errorf("key: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := f.hits.Load(); n != 1 {
+		t.Fatalf("concurrent logins must share one fetch, got %d", n)
+	}
+}
+
+func TestVerifyIDTokenWithFakeJWKS(t *testing.T) {
+	f := newFakeJWKS(t)
+	keys := f.rotate(t, "k1")
+
+	p := NewOIDC(OIDCConfig{
+		Name:     "test",
+		Issuer:   "https://issuer.example.com",
+		ClientID: "client",
+		AuthURL:  "https://issuer.example.com/auth",
+		TokenURL: "https://issuer.example.com/token",
+		JWKSURL:  f.srv.URL,
+	})
+	p.client = f.srv.Client()
+
+	sign := func(kid string, key *rsa.PrivateKey) string {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idTokenClaims{
+			Email: "user@example.com",
+			Nonce: "n",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://issuer.example.com",
+				Subject:   "42",
+				Audience:  jwt.ClaimStrings{"client"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		})
+		token.Header["kid"] = kid
+		raw, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return raw
+	}
+
+	ctx := context.Background()
+	id, err := p.VerifyIDToken(ctx, sign("k1", keys["k1"]), "n")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if id.Subject != "42" || id.Email != "user@example.com" {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+
+	// Подпись чужим ключом под известным kid не проходит
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if _, err := p.VerifyIDToken(ctx, sign("k1", other), "n"); err == nil {
+		t.Fatal("expected signature error for foreign key")
+	}
+
+	if _, err := p.VerifyIDToken(ctx, sign("k1", keys["k1"]), "other"); err == nil {
+		t.Fatal("expected nonce mismatch")
+	}
+}
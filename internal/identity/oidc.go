@@ -0,0 +1,313 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+// OIDCConfig — настройки OpenID Connect провайдера.
+// Если AuthURL, TokenURL и JWKSURL заданы, discovery-документ не запрашивается.
+type OIDCConfig struct {
+	Name         string
+	Issuer       string   // напр. https://keycloak.example.com/realms/main
+	ExtraIssuers []string // допустимые альтернативные значения iss (у Google есть вариант без https://)
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // по умолчанию openid, email, profile
+
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+
+	// Отклонять id_token с email_verified=false
+	RequireVerifiedEmail bool
+	// Провайдер присылает код POST-запросом (Apple)
+	FormPost bool
+	// Не отправлять PKCE (для провайдеров, которые его не поддерживают)
+	DisablePKCE bool
+}
+
+// OIDCProvider — универсальный OpenID Connect провайдер: discovery, JWKS, проверка id_token
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+	// Параллельные логины ждут одну загрузку discovery-документа
+	discovery singleflight.Group
+
+	mu     sync.Mutex
+	oauth  *oauth2.Config
+	jwks   *jwksCache
+	issuer []string
+}
+
+// NewOIDC создаёт провайдера; discovery выполняется лениво при первом логине,
+// чтобы недоступность провайдера не мешала старту сервера.
+func NewOIDC(cfg OIDCConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCProvider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *OIDCProvider) Name() string        { return p.cfg.Name }
+func (p *OIDCProvider) RedirectURL() string { return p.cfg.RedirectURL }
+func (p *OIDCProvider) FormPost() bool      { return p.cfg.FormPost }
+
+// AuthCodeURL строит ссылку на логин с state, nonce и (если не отключено) PKCE S256
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	conf, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce)}
+	if !p.cfg.DisablePKCE {
+		opts = append(opts, oauth2.S256ChallengeOption(verifier))
+	}
+	if p.cfg.FormPost {
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", "form_post"))
+	}
+
+	return conf.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange обменивает код на токены и проверяет полученный id_token (включая nonce)
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	conf, _, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if !p.cfg.DisablePKCE {
+		opts = append(opts, oauth2.VerifierOption(verifier))
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := conf.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("missing id_token in token response")
+	}
+
+	return p.VerifyIDToken(ctx, rawIDToken, nonce)
+}
+
+// idTokenClaims — стандартные claims id_token, которые нам нужны
+type idTokenClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+	Nonce         string   `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// VerifyIDToken локально проверяет id_token: подпись RS256 по JWKS провайдера,
+// iss, aud, exp, iat, email_verified и, если передан, nonce.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Identity, error) {
+	_, jwks, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			if kid == "" {
+				return nil, errors.New("missing kid")
+			}
+			return jwks.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.validIssuer(claims.Issuer) {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub")
+	}
+
+	if p.cfg.RequireVerifiedEmail && !bool(claims.EmailVerified) {
+		return nil, errors.New("email is not verified")
+	}
+
+	// nonce защищает от подстановки чужого id_token
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	return &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func (p *OIDCProvider) validIssuer(iss string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, allowed := range p.issuer {
+		if iss == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// discover загружает discovery-документ провайдера (один раз) и готовит oauth2-конфиг и JWKS.
+// Сетевой запрос идёт без p.mu, чтобы медленный issuer не блокировал остальные вызовы.
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, *jwksCache, error) {
+	p.mu.Lock()
+	conf, jwks := p.oauth, p.jwks
+	p.mu.Unlock()
+
+	if conf != nil {
+		return conf, jwks, nil
+	}
+
+	// Отмена запроса одного логина не должна обрывать загрузку, которую ждут остальные
+	_, err, _ := p.discovery.Do("discover", func() (any, error) {
+		return nil, p.load(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.oauth, p.jwks, nil
+}
+
+// load выполняет discovery (если адреса не заданы явно) и сохраняет конфигурацию провайдера
+func (p *OIDCProvider) load(ctx context.Context) error {
+	p.mu.Lock()
+	loaded := p.oauth != nil
+	p.mu.Unlock()
+
+	if loaded {
+		return nil
+	}
+
+	authURL, tokenURL, jwksURL := p.cfg.AuthURL, p.cfg.TokenURL, p.cfg.JWKSURL
+	issuer := p.cfg.Issuer
+
+	if authURL == "" || tokenURL == "" || jwksURL == "" {
+		doc, err := p.fetchDiscovery(ctx)
+		if err != nil {
+			return err
+		}
+		// По спецификации issuer в документе обязан совпадать с тем, у кого мы его спросили
+		if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(p.cfg.Issuer, "/") {
+			return fmt.Errorf("discovery issuer %q does not match configured %q", doc.Issuer, p.cfg.Issuer)
+		}
+		issuer = doc.Issuer
+		if authURL == "" {
+			authURL = doc.AuthorizationEndpoint
+		}
+		if tokenURL == "" {
+			tokenURL = doc.TokenEndpoint
+		}
+		if jwksURL == "" {
+			jwksURL = doc.JWKSURI
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint:     oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL},
+	}
+	p.jwks = newJWKSCache(jwksURL, p.client)
+	p.issuer = append([]string{issuer}, p.cfg.ExtraIssuers...)
+
+	return nil
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (p *OIDCProvider) fetchDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	url := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("discovery status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	return &doc, nil
+}
+
+// flexBool принимает и true, и "true" — Apple присылает email_verified строкой
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
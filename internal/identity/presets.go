@@ -0,0 +1,40 @@
+package identity
+
+import "golang.org/x/oauth2/google"
+
+// NewGoogle — Google как OIDC-провайдер. Эндпоинты известны заранее, discovery не нужен;
+// jwksURL можно переопределить (напр. на локальный фейковый сервер ключей в тестах).
+func NewGoogle(clientID, clientSecret, redirectURL, jwksURL string) *OIDCProvider {
+	if jwksURL == "" {
+		jwksURL = "https://www.googleapis.com/oauth2/v3/certs"
+	}
+
+	return NewOIDC(OIDCConfig{
+		Name:                 "google",
+		Issuer:               "https://accounts.google.com",
+		ExtraIssuers:         []string{"accounts.google.com"},
+		ClientID:             clientID,
+		ClientSecret:         clientSecret,
+		RedirectURL:          redirectURL,
+		AuthURL:              google.Endpoint.AuthURL,
+		TokenURL:             google.Endpoint.TokenURL,
+		JWKSURL:              jwksURL,
+		RequireVerifiedEmail: true,
+	})
+}
+
+// NewApple — Sign in with Apple. clientSecret — это ES256 JWT, который генерируется
+// из ключа Apple (.p8) и живёт до 6 месяцев; его нужно перевыпускать вне сервера.
+func NewApple(clientID, clientSecret, redirectURL string) *OIDCProvider {
+	return NewOIDC(OIDCConfig{
+		Name:         "apple",
+		Issuer:       "https://appleid.apple.com",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		// При запросе name/email Apple требует response_mode=form_post
+		Scopes:      []string{"openid", "name", "email"},
+		FormPost:    true,
+		DisablePKCE: true,
+	})
+}
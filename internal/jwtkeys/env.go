@@ -0,0 +1,215 @@
+package jwtkeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Секрет для локальной разработки; в production с ним сервер не запустится
+const devSecret = "dev-secret-change-me"
+
+// Минимальная длина HS256-секрета в production (256 бит)
+const minSecretLen = 32
+
+// fileConfig — содержимое JWT_KEYS_FILE:
+//
+//	{
+//	  "active": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-10", "alg": "EdDSA", "private_key_file": "jwt-2026-10.pem"},
+//	    {"kid": "2026-04", "alg": "RS256", "public_key_file": "jwt-2026-04.pub.pem", "retire_at": "2026-11-01T00:00:00Z"},
+//	    {"kid": "legacy", "alg": "HS256", "secret_env": "JWT_SECRET", "retire_at": "2026-10-20T00:00:00Z"}
+//	  ]
+//	}
+//
+// Пути к PEM-файлам считаются относительно самого файла. Ключ Ed25519 можно
+// сгенерировать так: openssl genpkey -algorithm ed25519 -out jwt.pem
+type fileConfig struct {
+	Active string    `json:"active"`
+	Keys   []fileKey `json:"keys"`
+}
+
+type fileKey struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`
+	PrivateKeyFile string     `json:"private_key_file,omitempty"`
+	PublicKeyFile  string     `json:"public_key_file,omitempty"` // только проверка
+	SecretEnv      string     `json:"secret_env,omitempty"`      // HS256: имя переменной с секретом
+	RetireAt       *time.Time `json:"retire_at,omitempty"`
+}
+
+// FromEnv собирает набор ключей из окружения:
+//   - JWT_KEYS_FILE — набор ключей с ротацией (см. fileConfig);
+//   - иначе JWT_SECRET — единственный HS256-ключ с kid "legacy", как раньше.
+//
+// В production (APP_ENV=production) без настоящего ключа возвращает ошибку
+// вместо молчаливого перехода на dev-секрет.
+func FromEnv() (*KeySet, error) {
+	production := os.Getenv("APP_ENV") == "production"
+
+	var (
+		ks  *KeySet
+		err error
+	)
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		ks, err = loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			if production {
+				return nil, errors.New("no signing key configured: set JWT_KEYS_FILE or JWT_SECRET")
+			}
+			log.Warn().Msg("JWT_SECRET is not set, using insecure development secret")
+			secret = devSecret
+		}
+
+		ks, err = New(LegacyKeyID, NewHMACKey(LegacyKeyID, []byte(secret)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if production {
+		for _, k := range ks.keys {
+			if !k.symmetric() {
+				continue
+			}
+			secret, _ := k.private.([]byte)
+			if string(secret) == devSecret || len(secret) < minSecretLen {
+				return nil, fmt.Errorf("key %q: HS256 secret is too weak for production", k.ID)
+			}
+		}
+	}
+
+	log.Info().Str("kid", ks.active.ID).Str("alg", ks.active.Algorithm).Int("keys", len(ks.keys)).Msg("jwt signing keys loaded")
+
+	return ks, nil
+}
+
+func loadFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var cfg fileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse keys file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	keys := make([]*Key, 0, len(cfg.Keys))
+	for _, fk := range cfg.Keys {
+		k, err := fk.load(dir)
+		if err != nil {
+			return nil, err
+		}
+		if fk.RetireAt != nil {
+			k.RetireAt = *fk.RetireAt
+		}
+		keys = append(keys, k)
+	}
+
+	return New(cfg.Active, keys...)
+}
+
+func (fk fileKey) load(dir string) (*Key, error) {
+	if fk.ID == "" {
+		return nil, errors.New("keys file: key without kid")
+	}
+
+	var (
+		k   *Key
+		err error
+	)
+
+	switch fk.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if fk.SecretEnv == "" {
+			return nil, fmt.Errorf("key %q: secret_env is required for HS256", fk.ID)
+		}
+		secret := os.Getenv(fk.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("key %q: %s is empty", fk.ID, fk.SecretEnv)
+		}
+		return NewHMACKey(fk.ID, []byte(secret)), nil
+
+	case jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg():
+		switch {
+		case fk.PrivateKeyFile != "":
+			k, err = loadPrivateKey(fk.ID, fk.Algorithm, resolve(dir, fk.PrivateKeyFile))
+		case fk.PublicKeyFile != "":
+			k, err = loadPublicKey(fk.ID, fk.Algorithm, resolve(dir, fk.PublicKeyFile))
+		default:
+			return nil, fmt.Errorf("key %q: private_key_file or public_key_file is required", fk.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("key %q: unsupported alg %q", fk.ID, fk.Algorithm)
+	}
+
+	if k.Algorithm != fk.Algorithm {
+		return nil, fmt.Errorf("key %q: file contains %s key, expected %s", fk.ID, k.Algorithm, fk.Algorithm)
+	}
+
+	return k, nil
+}
+
+func loadPrivateKey(id, alg, path string) (*Key, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	var key any
+	if alg == jwt.SigningMethodEdDSA.Alg() {
+		key, err = jwt.ParseEdPrivateKeyFromPEM(pem)
+	} else {
+		key, err = jwt.ParseRSAPrivateKeyFromPEM(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	return NewPrivateKey(id, key)
+}
+
+func loadPublicKey(id, alg, path string) (*Key, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	var key any
+	if alg == jwt.SigningMethodEdDSA.Alg() {
+		key, err = jwt.ParseEdPublicKeyFromPEM(pem)
+	} else {
+		key, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	return NewPublicKey(id, key)
+}
+
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,180 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv сбрасывает переменные, которые читает FromEnv
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range []string{"APP_ENV", "JWT_KEYS_FILE", "JWT_SECRET", "TEST_JWT_SECRET"} {
+		t.Setenv(k, "")
+	}
+}
+
+func TestFromEnvProductionRefusesWeakSecrets(t *testing.T) {
+	cases := map[string]string{
+		"missing":    "",
+		"dev secret": devSecret,
+		"too short":  strings.Repeat("s", minSecretLen-1),
+	}
+	for name, secret := range cases {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("APP_ENV", "production")
+			t.Setenv("JWT_SECRET", secret)
+
+			if _, err := FromEnv(); err == nil {
+				t.Fatal("expected production startup to be refused")
+			}
+		})
+	}
+}
+
+func TestFromEnvProductionAcceptsStrongSecret(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_SECRET", strings.Repeat("s", minSecretLen))
+
+	ks, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if ks.Active().ID != LegacyKeyID {
+		t.Fatalf("expected legacy key, got %q", ks.Active().ID)
+	}
+}
+
+func TestFromEnvDevelopmentFallsBackToDevSecret(t *testing.T) {
+	clearEnv(t)
+
+	ks, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if secret, _ := ks.Active().private.([]byte); string(secret) != devSecret {
+		t.Fatal("expected development secret outside production")
+	}
+}
+
+func TestFromEnvProductionRefusesWeakSecretInKeysFile(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	writeFile(t, dir, "keys.json", `{
+		"active": "ed",
+		"keys": [
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"},
+			{"kid": "legacy", "alg": "HS256", "secret_env": "TEST_JWT_SECRET"}
+		]
+	}`)
+	writeEdPrivateKey(t, dir, "ed.pem")
+
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_KEYS_FILE", filepath.Join(dir, "keys.json"))
+	t.Setenv("TEST_JWT_SECRET", "short")
+
+	if _, err := FromEnv(); err == nil {
+		t.Fatal("expected weak HS256 key in keys file to be refused in production")
+	}
+
+	t.Setenv("TEST_JWT_SECRET", strings.Repeat("s", minSecretLen))
+	ks, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if ks.Active().ID != "ed" || ks.Active().Algorithm != "EdDSA" {
+		t.Fatalf("unexpected active key %q/%s", ks.Active().ID, ks.Active().Algorithm)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	writeEdPrivateKey(t, dir, "ed.pem")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal rsa: %v", err)
+	}
+	writeFile(t, dir, "rsa.pub.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+
+	writeFile(t, dir, "keys.json", `{
+		"active": "ed",
+		"keys": [
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"},
+			{"kid": "rsa", "alg": "RS256", "public_key_file": "rsa.pub.pem", "retire_at": "2099-01-01T00:00:00Z"}
+		]
+	}`)
+
+	ks, err := loadFile(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		t.Fatalf("loadFile: %v", err)
+	}
+	if ks.Active().ID != "ed" {
+		t.Fatalf("unexpected active key %q", ks.Active().ID)
+	}
+	rsaLoaded := ks.keys["rsa"]
+	if rsaLoaded == nil || rsaLoaded.private != nil || rsaLoaded.RetireAt.Year() != 2099 {
+		t.Fatalf("unexpected verify-only key %+v", rsaLoaded)
+	}
+}
+
+func TestLoadFileRejectsInvalidKeys(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	writeEdPrivateKey(t, dir, "ed.pem")
+
+	cases := map[string]string{
+		// В файле Ed25519, а заявлен RS256
+		"alg mismatch":     `{"active": "k", "keys": [{"kid": "k", "alg": "RS256", "private_key_file": "ed.pem"}]}`,
+		"unsupported alg":  `{"active": "k", "keys": [{"kid": "k", "alg": "ES256", "private_key_file": "ed.pem"}]}`,
+		"missing kid":      `{"active": "k", "keys": [{"alg": "EdDSA", "private_key_file": "ed.pem"}]}`,
+		"missing file":     `{"active": "k", "keys": [{"kid": "k", "alg": "EdDSA", "private_key_file": "nope.pem"}]}`,
+		"no key material":  `{"active": "k", "keys": [{"kid": "k", "alg": "EdDSA"}]}`,
+		"hs256 no env":     `{"active": "k", "keys": [{"kid": "k", "alg": "HS256"}]}`,
+		"hs256 empty env":  `{"active": "k", "keys": [{"kid": "k", "alg": "HS256", "secret_env": "TEST_JWT_SECRET"}]}`,
+		"malformed json":   `{"active": `,
+		"unknown active":   `{"active": "x", "keys": [{"kid": "k", "alg": "EdDSA", "private_key_file": "ed.pem"}]}`,
+		"public as active": `{"active": "k", "keys": [{"kid": "k", "alg": "EdDSA", "public_key_file": "ed.pem"}]}`,
+	}
+	for name, cfg := range cases {
+		path := writeFile(t, dir, "keys.json", cfg)
+		if _, err := loadFile(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func writeEdPrivateKey(t *testing.T, dir, name string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshal ed25519: %v", err)
+	}
+	writeFile(t, dir, name, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+}
@@ -0,0 +1,60 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JWK — публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+// JWKSet — документ /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS отдаёт публичные ключи, по которым другие сервисы могут проверять наши токены.
+// HS256-ключи не публикуются (это секрет), выведенные из ротации — тоже.
+func (ks *KeySet) JWKS() JWKSet {
+	now := time.Now()
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, k := range ks.keys {
+		if k.symmetric() || k.retired(now) {
+			continue
+		}
+
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+		switch pub := k.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	// Стабильный порядок, чтобы ответ не менялся между запросами
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
@@ -0,0 +1,152 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LegacyKeyID — kid, под которым живёт HS256-секрет из JWT_SECRET.
+// Токены без kid (выпущенные до ротации) проверяются этим ключом.
+const LegacyKeyID = "legacy"
+
+// Key — один ключ подписи наших JWT
+type Key struct {
+	ID        string
+	Algorithm string // EdDSA, RS256 или HS256
+	// После RetireAt токены с этим kid больше не принимаются (нулевое значение — бессрочно)
+	RetireAt time.Time
+
+	private any // ed25519.PrivateKey, *rsa.PrivateKey или []byte (HS256); nil — только проверка
+	public  any // ed25519.PublicKey, *rsa.PublicKey или []byte (HS256)
+}
+
+// NewHMACKey — симметричный HS256-ключ (устаревший вариант, публиковать его нельзя)
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: jwt.SigningMethodHS256.Alg(), private: secret, public: secret}
+}
+
+// NewPrivateKey оборачивает приватный ключ Ed25519 или RSA
+func NewPrivateKey(id string, key any) (*Key, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: jwt.SigningMethodEdDSA.Alg(), private: k, public: k.Public()}, nil
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: jwt.SigningMethodRS256.Alg(), private: k, public: &k.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported private key type %T", id, key)
+	}
+}
+
+// NewPublicKey — ключ только для проверки (напр. выведенный из ротации, приватная часть уже удалена)
+func NewPublicKey(id string, key any) (*Key, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return &Key{ID: id, Algorithm: jwt.SigningMethodEdDSA.Alg(), public: k}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: jwt.SigningMethodRS256.Alg(), public: k}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported public key type %T", id, key)
+	}
+}
+
+func (k *Key) symmetric() bool {
+	return k.Algorithm == jwt.SigningMethodHS256.Alg()
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && now.After(k.RetireAt)
+}
+
+// KeySet — активный ключ, которым подписываем, и все ключи, которым ещё доверяем.
+// Ротация: новый ключ добавляется в набор заранее (попадает в JWKS), затем становится
+// активным; старый остаётся для проверки, пока не истекут подписанные им токены.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// New собирает набор; active должен быть среди keys и иметь приватную часть
+func New(active string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New("key without kid")
+		}
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate kid %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	ks.active = ks.keys[active]
+	if ks.active == nil {
+		return nil, fmt.Errorf("active key %q not found", active)
+	}
+	if ks.active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", active)
+	}
+	if ks.active.retired(time.Now()) {
+		return nil, fmt.Errorf("active key %q is retired", active)
+	}
+
+	return ks, nil
+}
+
+// Active — ключ, которым подписываются новые токены
+func (ks *KeySet) Active() *Key {
+	return ks.active
+}
+
+// Sign подписывает claims активным ключом и проставляет kid в заголовок
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(ks.active.Algorithm), claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.private)
+}
+
+// Parse проверяет подпись по kid из заголовка и разбирает claims.
+// Алгоритм токена обязан совпадать с алгоритмом ключа — иначе подпись HS256
+// можно было бы подделать публичным ключом.
+func (ks *KeySet) Parse(raw string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods(ks.methods())}, opts...)
+	_, err := jwt.ParseWithClaims(raw, claims, ks.keyfunc, opts...)
+	return err
+}
+
+func (ks *KeySet) keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = LegacyKeyID
+	}
+
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if k.retired(time.Now()) {
+		return nil, fmt.Errorf("key %q is retired", kid)
+	}
+	if t.Method.Alg() != k.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", t.Method.Alg(), kid)
+	}
+
+	return k.public, nil
+}
+
+func (ks *KeySet) methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, k := range ks.keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			methods = append(methods, k.Algorithm)
+		}
+	}
+	return methods
+}
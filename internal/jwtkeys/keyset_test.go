@@ -0,0 +1,251 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newEdKey(t *testing.T, id string) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519: %v", err)
+	}
+	k, err := NewPrivateKey(id, priv)
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	return k
+}
+
+func newRSAKey(t *testing.T, id string) (*Key, *rsa.PrivateKey) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa: %v", err)
+	}
+	k, err := NewPrivateKey(id, priv)
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	return k, priv
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	ks, err := New("a", newEdKey(t, "a"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	raw, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(raw, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	if token.Header["kid"] != "a" || token.Method.Alg() != "EdDSA" {
+		t.Fatalf("unexpected header %v", token.Header)
+	}
+
+	var claims jwt.RegisteredClaims
+	if err := ks.Parse(raw, &claims); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if claims.Subject != "42" {
+		t.Fatalf("unexpected subject %q", claims.Subject)
+	}
+}
+
+func TestParseRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, priv := newRSAKey(t, "rsa")
+	// HS256 в наборе есть, поэтому сам алгоритм допустим — отсечь подделку должен kid
+	ks, err := New("rsa", rsaKey, NewHMACKey(LegacyKeyID, []byte(strings.Repeat("s", 32))))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	// Классическая атака: HS256, подписанный публичным RSA-ключом как секретом
+	for _, secret := range [][]byte{pubPEM, pubDER} {
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		forged.Header["kid"] = "rsa"
+		raw, err := forged.SignedString(secret)
+		if err != nil {
+			t.Fatalf("sign forged: %v", err)
+		}
+		if err := ks.Parse(raw, &jwt.RegisteredClaims{}); err == nil {
+			t.Fatal("expected HS256 token with RSA kid to be rejected")
+		}
+	}
+
+	// Алгоритм вне набора отклоняется ещё до выбора ключа
+	onlyRSA, err := New("rsa", rsaKey)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "rsa"
+	raw, _ := forged.SignedString(pubPEM)
+	if err := onlyRSA.Parse(raw, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("expected HS256 token to be rejected by RSA-only keyset")
+	}
+}
+
+func TestParseKidSelection(t *testing.T) {
+	a, b := newEdKey(t, "a"), newEdKey(t, "b")
+	legacy := NewHMACKey(LegacyKeyID, []byte(strings.Repeat("x", 32)))
+
+	ks, err := New("a", a, b, legacy)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Токен без kid проверяется legacy-ключом
+	noKid, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString(legacy.private)
+	if err != nil {
+		t.Fatalf("sign legacy: %v", err)
+	}
+	if err := ks.Parse(noKid, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("expected token without kid to verify with legacy key: %v", err)
+	}
+
+	// Подпись ключом a под kid b не проходит
+	wrongKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	wrongKid.Header["kid"] = "b"
+	raw, err := wrongKid.SignedString(a.private)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := ks.Parse(raw, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("expected signature mismatch for wrong kid")
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	unknown.Header["kid"] = "c"
+	raw, _ = unknown.SignedString(a.private)
+	if err := ks.Parse(raw, &jwt.RegisteredClaims{}); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("expected unknown key id, got %v", err)
+	}
+}
+
+func TestRotatedKeyVerifiesButDoesNotSign(t *testing.T) {
+	oldKey, newKey := newEdKey(t, "old"), newEdKey(t, "new")
+
+	before, err := New("old", oldKey)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	oldToken, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Ротация: новый ключ активен, старый доживает до RetireAt
+	oldKey.RetireAt = time.Now().Add(time.Hour)
+	after, err := New("new", newKey, oldKey)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if after.Active().ID != "new" {
+		t.Fatalf("expected new key to sign, got %q", after.Active().ID)
+	}
+
+	if err := after.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token of rotated key must still verify: %v", err)
+	}
+
+	newToken, err := after.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	token, _, _ := jwt.NewParser().ParseUnverified(newToken, &jwt.RegisteredClaims{})
+	if token.Header["kid"] != "new" {
+		t.Fatalf("expected kid new, got %v", token.Header["kid"])
+	}
+
+	// Выведенный из ротации ключ в JWKS ещё публикуется
+	if jwks := after.JWKS(); len(jwks.Keys) != 2 {
+		t.Fatalf("expected both keys in JWKS, got %d", len(jwks.Keys))
+	}
+
+	// После RetireAt токены старого ключа отклоняются и ключ пропадает из JWKS
+	oldKey.RetireAt = time.Now().Add(-time.Second)
+	if err := after.Parse(oldToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("expected token of retired key to be rejected")
+	}
+	if jwks := after.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "new" {
+		t.Fatalf("expected only new key in JWKS, got %+v", jwks.Keys)
+	}
+
+	// Ушедший в отставку ключ не может стать активным
+	if _, err := New("old", oldKey, newKey); err == nil {
+		t.Fatal("expected retired key to be refused as active")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	a := newEdKey(t, "a")
+	pub, err := NewPublicKey("pub", a.public)
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+
+	cases := map[string]func() (*KeySet, error){
+		"missing active":   func() (*KeySet, error) { return New("x", a) },
+		"duplicate kid":    func() (*KeySet, error) { return New("a", a, newEdKey(t, "a")) },
+		"public-only sign": func() (*KeySet, error) { return New("pub", a, pub) },
+		"empty kid":        func() (*KeySet, error) { return New("a", a, newEdKey(t, "")) },
+	}
+	for name, build := range cases {
+		if _, err := build(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := NewPrivateKey("bad", []byte("secret")); err == nil {
+		t.Error("expected unsupported private key type to be rejected")
+	}
+}
+
+func TestJWKSSkipsSymmetricKeys(t *testing.T) {
+	rsaKey, _ := newRSAKey(t, "rsa")
+	ks, err := New("rsa", rsaKey, newEdKey(t, "ed"), NewHMACKey(LegacyKeyID, []byte("secret")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	set := ks.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %+v", set.Keys)
+	}
+	// Порядок стабильный — по kid
+	if set.Keys[0].Kid != "ed" || set.Keys[0].Kty != "OKP" || set.Keys[0].Crv != "Ed25519" {
+		t.Fatalf("unexpected ed25519 jwk %+v", set.Keys[0])
+	}
+	if set.Keys[1].Kid != "rsa" || set.Keys[1].Kty != "RSA" || set.Keys[1].E != "AQAB" {
+		t.Fatalf("unexpected rsa jwk %+v", set.Keys[1])
+	}
+}
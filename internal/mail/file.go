@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSender складывает письма в .eml-файлы в Dir, а если Dir пустой — пишет их в Out
+// (по умолчанию stdout). Для разработки и тестов без почтового сервера.
+type FileSender struct {
+	From string
+	Dir  string
+	Out  io.Writer
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	body, err := render(s.From, msg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Dir == "" {
+		out := s.Out
+		if out == nil {
+			out = os.Stdout
+		}
+		_, err := fmt.Fprintf(out, "----- mail -----\n%s\n----- end mail -----\n", body)
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create mail dir: %w", err)
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	if err := os.WriteFile(filepath.Join(s.Dir, name), body, 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+
+	return nil
+}
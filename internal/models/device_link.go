@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DeviceLink — запрос нового устройства на вход по QR-коду
+type DeviceLink struct {
+	ID         int64      `json:"id"`
+	ClientName string     `json:"client_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ApprovedBy *int64     `json:"approved_by,omitempty"` // пользователь, подтвердивший вход
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"` // устройство забрало токены
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Роли участников группы
+const (
+	RoleOwner  = "owner"  // всё, включая назначение админов; один на группу
+	RoleAdmin  = "admin"  // добавляет и удаляет участников, меняет название и аватар, закрепляет сообщения
+	RoleMember = "member" // пишет сообщения и может выйти
+)
+
+// RoleRank — старшинство роли для проверок прав
+func RoleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ConversationMember — участник переписки
+type ConversationMember struct {
+	UserProfile
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Действия служебных сообщений
+const (
+	SystemGroupCreated    = "group_created"
+	SystemMembersAdded    = "members_added"
+	SystemMemberRemoved   = "member_removed"
+	SystemMemberLeft      = "member_left"
+	SystemMemberJoined    = "member_joined" // по ссылке-приглашению
+	SystemTitleChanged    = "title_changed"
+	SystemAvatarChanged   = "avatar_changed"
+	SystemRoleChanged     = "role_changed"
+	SystemOwnerChanged    = "owner_changed"
+	SystemMessagePinned   = "message_pinned"
+	SystemMessageUnpinned = "message_unpinned"
+)
+
+// SystemEvent — что произошло в группе (для клиентов, которые рисуют служебные сообщения сами)
+type SystemEvent struct {
+	Action    string  `json:"action"`
+	ActorID   int64   `json:"actor_id"`
+	UserIDs   []int64 `json:"user_ids,omitempty"` // кого добавили/удалили/назначили
+	Title     string  `json:"title,omitempty"`
+	Role      string  `json:"role,omitempty"`
+	MessageID int64   `json:"message_id,omitempty"`
+}
+
+// Text — текст служебного сообщения; names — имена участников события по id
+func (e *SystemEvent) Text(names map[int64]string) string {
+	name := func(id int64) string {
+		if n := names[id]; n != "" {
+			return n
+		}
+		return "Someone"
+	}
+	users := make([]string, len(e.UserIDs))
+	for i, id := range e.UserIDs {
+		users[i] = name(id)
+	}
+	list := strings.Join(users, ", ")
+	actor := name(e.ActorID)
+
+	switch e.Action {
+	case SystemGroupCreated:
+		return fmt.Sprintf("%s created the group %q", actor, e.Title)
+	case SystemMembersAdded:
+		return fmt.Sprintf("%s added %s", actor, list)
+	case SystemMemberRemoved:
+		return fmt.Sprintf("%s removed %s", actor, list)
+	case SystemMemberLeft:
+		return fmt.Sprintf("%s left", actor)
+	case SystemMemberJoined:
+		return fmt.Sprintf("%s joined via invite link", actor)
+	case SystemTitleChanged:
+		return fmt.Sprintf("%s changed the title to %q", actor, e.Title)
+	case SystemAvatarChanged:
+		return fmt.Sprintf("%s changed the group photo", actor)
+	case SystemRoleChanged:
+		if e.Role == RoleAdmin {
+			return fmt.Sprintf("%s made %s an admin", actor, list)
+		}
+		return fmt.Sprintf("%s removed %s as admin", actor, list)
+	case SystemOwnerChanged:
+		return fmt.Sprintf("%s is now the owner", list)
+	case SystemMessagePinned:
+		return fmt.Sprintf("%s pinned a message", actor)
+	case SystemMessageUnpinned:
+		return fmt.Sprintf("%s unpinned a message", actor)
+	default:
+		return actor + " updated the group"
+	}
+}
+
+// GroupInvite — ссылка-приглашение в группу
+type GroupInvite struct {
+	ID               int64      `json:"id"`
+	ConversationID   int64      `json:"conversation_id"`
+	Token            string     `json:"token"`
+	CreatedBy        *int64     `json:"created_by,omitempty"`
+	RequiresApproval bool       `json:"requires_approval"` // вступление только после одобрения админом
+	MaxUses          *int       `json:"max_uses,omitempty"`
+	Uses             int        `json:"uses"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// GroupPreview — что видно о группе по ссылке до вступления
+type GroupPreview struct {
+	ConversationID   int64   `json:"conversation_id"`
+	Title            *string `json:"title,omitempty"`
+	Avatar           *string `json:"avatar,omitempty"`
+	MemberCount      int     `json:"member_count"`
+	RequiresApproval bool    `json:"requires_approval"`
+	IsMember         bool    `json:"is_member"` // пользователь уже в группе
+	Requested        bool    `json:"requested"` // заявка пользователя ждёт одобрения
+}
+
+// JoinRequest — заявка на вступление в группу
+type JoinRequest struct {
+	UserProfile
+	InviteID  *int64    `json:"invite_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// Виды переписок
+const (
+	ConversationDirect = "direct" // личная переписка двух пользователей
+	ConversationGroup  = "group"  // группа с названием, ролями и управлением участниками
+)
+
+// Conversation — переписка (чат)
+type Conversation struct {
+	ID              int64      `json:"id"`
+	Kind            string     `json:"kind"`
+	DirectKey       *string    `json:"-"` // "<меньший id>:<больший id>" для личных переписок
+	Title           *string    `json:"title,omitempty"`
+	Avatar          *string    `json:"avatar,omitempty"` // URL аватара группы
+	CreatedBy       *int64     `json:"created_by,omitempty"`
+	PinnedMessageID *int64     `json:"pinned_message_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastMessageID   *int64     `json:"last_message_id,omitempty"`
+	LastMessageAt   *time.Time `json:"last_message_at,omitempty"`
+}
+
+// ConversationSummary — переписка в списке чатов пользователя
+type ConversationSummary struct {
+	Conversation
+	Role        string       `json:"role"`           // роль пользователя в переписке
+	Peer        *UserProfile `json:"peer,omitempty"` // собеседник в личной переписке
+	LastMessage *Message     `json:"last_message,omitempty"`
+	UnreadCount int          `json:"unread_count"`
+}
+
+// Виды сообщений
+const (
+	MessageText   = "text"
+	MessageSystem = "system" // служебное: изменение группы, в System — что произошло
+)
+
+// Message — сообщение в переписке
+type Message struct {
+	ID             int64        `json:"id"`
+	ConversationID int64        `json:"conversation_id"`
+	SenderID       int64        `json:"sender_id"` // у служебных — кто совершил действие
+	Kind           string       `json:"kind"`
+	Content        string       `json:"content"` // у служебных — текст события ("Alice added Bob")
+	System         *SystemEvent `json:"system,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"` // время сервера
+	EditedAt       *time.Time   `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"`
+	DeletedFor     string       `json:"deleted_for,omitempty"` // у удалённого: everyone или me; content пустой
+	Seq            int64        `json:"seq,omitempty"`         // номер в ленте получателя (только в событиях и синхронизации)
+
+	Receipts       []MessageReceipt `json:"receipts,omitempty"`        // отметки получателей (в истории, только у своих сообщений)
+	DeliveredCount int              `json:"delivered_count,omitempty"` // сколько получателей получили (в истории)
+	ReadCount      int              `json:"read_count,omitempty"`      // сколько получателей прочитали (в истории)
+	Reactions      []ReactionCount  `json:"reactions,omitempty"`       // реакции (в истории)
+}
+
+// ReactionCount — сколько человек поставили эмодзи на сообщение
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	Me    bool   `json:"me"` // среди них текущий пользователь
+}
+
+// Кому удалено сообщение
+const (
+	DeletedForEveryone = "everyone" // автором, у всех участников
+	DeletedForMe       = "me"       // только у этого пользователя
+)
+
+// Tombstone превращает сообщение в отметку об удалении
+func (m *Message) Tombstone(deletedFor string, at time.Time) {
+	m.Content = ""
+	m.System = nil
+	m.DeletedFor = deletedFor
+	m.DeletedAt = &at
+}
+
+// MessageEdit — версия сообщения до правки
+type MessageEdit struct {
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"edited_at"` // когда эту версию заменили
+}
+
+// MessageReceipt — когда получатель получил и прочитал сообщение
+type MessageReceipt struct {
+	UserID      int64      `json:"user_id"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
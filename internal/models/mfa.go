@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserMFA — настройки TOTP второго фактора пользователя
+type UserMFA struct {
+	UserID       int64      `json:"user_id"`
+	Secret       string     `json:"-"` // base32 секрет TOTP
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"` // nil — подключение не завершено
+}
+
+// Enabled — второй фактор подтверждён и действует
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.ConfirmedAt != nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// PersonalAccessToken — долгоживущий токен для скриптов и ботов (сам токен не хранится, только хеш)
+type PersonalAccessToken struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	CreatedBy   *int64     `json:"created_by,omitempty"` // кто выпустил (владелец, если токен бота)
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"token_prefix"` // начало токена, чтобы узнать его в списке
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	AckedSeq    int64      `json:"acked_seq"` // до какого seq клиент с токеном подтвердил получение сообщений
+}
+
+// HasScope проверяет, разрешено ли токену действие
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Active — токен не отозван и не истёк
+func (t *PersonalAccessToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RefreshToken — запись о выданном refresh-токене (сам токен не хранится, только хеш)
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	SessionID *int64     `json:"session_id,omitempty"` // сессия (устройство), которой выдан токен
+	FamilyID  string     `json:"family_id"`            // общий для всей цепочки ротаций одного логина
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`    // когда токен был обменян на новый
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // когда токен был отозван
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session — залогиненное устройство пользователя
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	ClientName string     `json:"client_name"` // имя клиента из X-Client-Name (напр. "iOS app")
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	AckedSeq   int64      `json:"acked_seq"` // до какого seq устройство подтвердило получение сообщений
+}
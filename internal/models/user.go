@@ -4,12 +4,38 @@ import "time"
 
 // User представляет пользователя в системе
 type User struct {
+	ID         int64     `json:"id"`
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	Picture    string    `json:"picture"` // URL аватара
+	IsBot      bool      `json:"is_bot"`
+	BotOwnerID *int64    `json:"bot_owner_id,omitempty"` // владелец бота (только у ботов)
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"` // когда закрылся последний сокет
+	HideLastSeen bool       `json:"hide_last_seen"`         // не показывать last_seen_at другим
+}
+
+// UserProfile — то, что о пользователе видят другие (без email и служебных полей)
+type UserProfile struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	IsBot   bool   `json:"is_bot"`
+}
+
+// Profile отдаёт публичную часть пользователя
+func (u *User) Profile() UserProfile {
+	return UserProfile{ID: u.ID, Name: u.Name, Picture: u.Picture, IsBot: u.IsBot}
+}
+
+// UserIdentity — способ входа пользователя через внешнего провайдера
+type UserIdentity struct {
 	ID        int64     `json:"id"`
-	GoogleID  string    `json:"google_id"`  // Google sub (subject)
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"` // google, github, apple, ...
+	Subject   string    `json:"subject"`  // ID пользователя у провайдера
 	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	Picture   string    `json:"picture"`    // URL аватара
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
-
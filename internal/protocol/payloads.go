@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Validator — payload, который умеет проверить себя после разбора
+type Validator interface {
+	Validate() error
+}
+
+// DecodePayload разбирает payload кадра в v и проверяет его
+func DecodePayload(env *Envelope, v Validator) error {
+	if len(env.Payload) == 0 {
+		return errors.New("missing payload")
+	}
+	if err := json.Unmarshal(env.Payload, v); err != nil {
+		return errors.New("payload does not match schema")
+	}
+	return v.Validate()
+}
+
+// AuthPayload — токен доступа (наш JWT или персональный токен)
+type AuthPayload struct {
+	Token string `json:"token"`
+}
+
+func (p *AuthPayload) Validate() error {
+	if p.Token == "" {
+		return errors.New("token is required")
+	}
+	return nil
+}
+
+// AuthOKPayload — до какого времени действует соединение без нового кадра auth
+type AuthOKPayload struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// MessageSendPayload — новое сообщение: личное (ToUserID) или в переписку (ConversationID)
+type MessageSendPayload struct {
+	ToUserID       int64  `json:"to_user_id,omitempty"`
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Content        string `json:"content"`
+}
+
+func (p *MessageSendPayload) Validate() error {
+	if (p.ToUserID > 0) == (p.ConversationID > 0) {
+		return errors.New("exactly one of to_user_id and conversation_id is required")
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+// SyncPayload — позиция в ленте пользователя (sync.ack и sync.done)
+type SyncPayload struct {
+	Seq int64 `json:"seq"`
+}
+
+func (p *SyncPayload) Validate() error {
+	if p.Seq <= 0 {
+		return errors.New("seq is required")
+	}
+	return nil
+}
+
+// TypingPayload — пользователь печатает (или перестал) в переписке.
+// UserID и ExpiresIn заполняет сервер при рассылке.
+type TypingPayload struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id,omitempty"`
+	Typing         bool  `json:"typing"`
+	ExpiresIn      int   `json:"expires_in,omitempty"` // через сколько секунд погасить индикатор без нового события
+}
+
+func (p *TypingPayload) Validate() error {
+	if p.ConversationID <= 0 {
+		return errors.New("conversation_id is required")
+	}
+	return nil
+}
+
+// Статусы присутствия
+const (
+	PresenceOnline  = "online"
+	PresenceIdle    = "idle"
+	PresenceOffline = "offline"
+)
+
+// PresencePayload — статус пользователя. Клиент присылает только Status
+// (online или idle — например, приложение свёрнуто), остальное заполняет сервер.
+type PresencePayload struct {
+	UserID     int64      `json:"user_id,omitempty"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"` // только для offline, если пользователь не скрыл
+}
+
+func (p *PresencePayload) Validate() error {
+	if p.Status != PresenceOnline && p.Status != PresenceIdle {
+		return errors.New("status must be online or idle")
+	}
+	return nil
+}
+
+// MessageDeletedPayload — сообщение удалено: For — everyone (автором у всех) или me
+// (пользователем у себя, приходит только на его устройства)
+type MessageDeletedPayload struct {
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	For            string    `json:"for"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// ReactionPayload — пользователь поставил или снял реакцию на сообщение
+type ReactionPayload struct {
+	ConversationID int64  `json:"conversation_id"`
+	MessageID      int64  `json:"message_id"`
+	UserID         int64  `json:"user_id"`
+	Emoji          string `json:"emoji"`
+}
+
+// Виды отметок о сообщениях
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+// ReceiptPayload — пользователь получил/прочитал сообщения переписки до MessageID включительно
+type ReceiptPayload struct {
+	ConversationID int64     `json:"conversation_id"`
+	UserID         int64     `json:"user_id"`
+	MessageID      int64     `json:"message_id"`
+	Kind           string    `json:"kind"`
+	At             time.Time `json:"at"`
+}
+
+// CallSignal — сигнал WebRTC (offer/answer/ICE candidate/hangup) для другого пользователя.
+// FromUserID заполняет сервер по токену.
+type CallSignal struct {
+	CallID     string          `json:"call_id,omitempty"` // идентификатор звонка, выбирает звонящий
+	FromUserID int64           `json:"from_user_id"`
+	ToUserID   int64           `json:"to_user_id"`
+	Payload    json.RawMessage `json:"payload"` // SDP или ICE candidate
+}
+
+func (p *CallSignal) Validate() error {
+	if p.ToUserID <= 0 {
+		return errors.New("to_user_id is required")
+	}
+	if len(p.CallID) > 64 {
+		return errors.New("call_id is too long")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package protocol описывает формат кадров WebSocket событий: конверт,
+// типы событий и их payload'ы. Версия протокола согласуется через
+// заголовок Sec-WebSocket-Protocol; JSON Schema версии публикуется
+// для генерации типизированных клиентов (см. Schema).
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// V1 — текущая версия протокола (значение Sec-WebSocket-Protocol)
+const V1 = "yeoboseyo.v1"
+
+// Subprotocols — поддерживаемые версии в порядке предпочтения
+var Subprotocols = []string{V1}
+
+// Типы событий
+const (
+	// Клиент → сервер
+	TypeAuth        = "auth"         // первый кадр, если токена не было в запросе
+	TypeMessageSend = "message.send" // отправить сообщение
+	TypeSyncAck     = "sync.ack"     // устройство получило сообщения до seq включительно
+
+	// Сервер → клиент
+	TypeAuthOK          = "auth.ok"          // токен из кадра auth принят (ref — id кадра)
+	TypeMessageNew      = "message.new"      // новое сообщение в переписке
+	TypeMessageAck      = "message.ack"      // сообщение отправителя сохранено (ref — id кадра message.send)
+	TypeMessageEdited   = "message.edited"   // автор исправил сообщение
+	TypeMessageDeleted  = "message.deleted"  // сообщение удалено для всех или для этого пользователя
+	TypeReactionAdded   = "reaction.added"   // участник поставил реакцию на сообщение
+	TypeReactionRemoved = "reaction.removed" // участник снял реакцию
+	TypeSyncDone        = "sync.done"        // пропущенное с ?since= досланы, дальше — живые события
+	TypeReceipt         = "receipt"          // сообщения доставлены / прочитаны
+	TypeError           = "error"            // ошибка обработки кадра (ref — id кадра)
+
+	// В обе стороны
+	TypePresence      = "presence" // клиент: активен ли он (online/idle); сервер: статус собеседника
+	TypeTyping        = "typing"
+	TypeCallOffer     = "call.offer"
+	TypeCallAnswer    = "call.answer"
+	TypeCallCandidate = "call.candidate"
+	TypeCallHangup    = "call.hangup"
+)
+
+// Envelope — конверт любого кадра
+type Envelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`  // id кадра у отправителя; на него ссылаются ответы
+	Ref     string          `json:"ref,omitempty"` // id кадра, ответом на который является этот
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Коды ошибок в кадре error
+const (
+	CodeInvalidFrame    = "invalid_frame"    // не JSON или нет type
+	CodeUnsupportedType = "unsupported_type" // неизвестный тип или не для клиента
+	CodeInvalidPayload  = "invalid_payload"  // payload не прошёл проверку
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeUnavailable     = "unavailable" // получатель не в сети
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal"
+)
+
+// ErrorPayload — payload кадра error
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Decode разбирает кадр и проверяет конверт
+func Decode(data []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, errors.New("frame is not a JSON object")
+	}
+	if env.Type == "" {
+		return nil, errors.New("missing type")
+	}
+	if len(env.ID) > 64 {
+		return nil, errors.New("id is too long")
+	}
+	return &env, nil
+}
+
+// New собирает кадр с payload
+func New(typ string, payload any) (*Envelope, error) {
+	env := &Envelope{Type: typ}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		env.Payload = raw
+	}
+	return env, nil
+}
+
+// Error собирает кадр ошибки в ответ на кадр ref
+func Error(ref, code, message string) *Envelope {
+	env, _ := New(TypeError, ErrorPayload{Code: code, Message: message})
+	env.Ref = ref
+	return env
+}
@@ -0,0 +1,15 @@
+package protocol
+
+import "embed"
+
+//go:embed schema/*.json
+var schemas embed.FS
+
+// Schema отдаёт JSON Schema версии протокола (nil — версия неизвестна)
+func Schema(version string) []byte {
+	data, err := schemas.ReadFile("schema/" + version + ".json")
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет из RFC 6238, приложение B: ASCII "12345678901234567890" в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Тестовые векторы RFC 6238 для SHA1. В RFC коды 8-значные; наши 6-значные —
+// это их последние 6 цифр.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestRFC6238Vectors(t *testing.T) {
+	key, err := b32.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	for _, v := range rfcVectors {
+		step := v.unix / int64(Period.Seconds())
+		if got := generate(key, step); got != v.code {
+			t.Errorf("generate(t=%d) = %s, want %s", v.unix, got, v.code)
+		}
+
+		gotStep, ok := Validate(rfcSecret, v.code, time.Unix(v.unix, 0), 0)
+		if !ok || gotStep != step {
+			t.Errorf("Validate(t=%d) = %d, %v; want %d, true", v.unix, gotStep, ok, step)
+		}
+	}
+}
+
+func TestValidateSkewWindow(t *testing.T) {
+	const code = "005924" // t=1234567890
+	at := time.Unix(1234567890, 0)
+	step := at.Unix() / int64(Period.Seconds())
+
+	cases := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same step", 0, true},
+		{"one step later", Period, true},
+		{"one step earlier", -Period, true},
+		{"two steps later", 2 * Period, false},
+		{"two steps earlier", -2 * Period, false},
+	}
+	for _, c := range cases {
+		got, ok := Validate(rfcSecret, code, at.Add(c.offset), 1)
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+		}
+		if ok && got != step {
+			t.Errorf("%s: step = %d, want %d", c.name, got, step)
+		}
+	}
+
+	// Без допуска соседний шаг не принимается
+	if _, ok := Validate(rfcSecret, code, at.Add(Period), 0); ok {
+		t.Error("expected code from previous step to be rejected with zero skew")
+	}
+}
+
+func TestValidateRejectsMalformed(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+
+	for _, code := range []string{"", "00592", "0059240", "00592a", "005 924"} {
+		if _, ok := Validate(rfcSecret, code, at, 1); ok {
+			t.Errorf("Validate(%q) accepted malformed code", code)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "005924", at, 1); ok {
+		t.Error("Validate accepted code for invalid secret")
+	}
+}
+
+func TestValidateNormalizesInput(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	if _, ok := Validate(" "+strings.ToLower(rfcSecret)+" ", " 005924 ", at, 0); !ok {
+		t.Error("expected lowercase secret and padded code to be accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if a == b {
+		t.Fatal("secrets must be random")
+	}
+
+	key, err := b32.DecodeString(a)
+	if err != nil {
+		t.Fatalf("secret is not base32: %v", err)
+	}
+	if len(key) != 20 {
+		t.Fatalf("expected 160-bit secret, got %d bytes", len(key))
+	}
+}
+
+func TestURI(t *testing.T) {
+	raw := URI("Yeoboseyo", "user@example.com", rfcSecret)
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Fatalf("unexpected uri %s", raw)
+	}
+	if u.Path != "/Yeoboseyo:user@example.com" {
+		t.Fatalf("unexpected label %q", u.Path)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Yeoboseyo",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package typing хранит, кто сейчас печатает в какой переписке. Состояние
+// эфемерное: живёт в памяти инстанса и никогда не пишется в БД.
+package typing
+
+import (
+	"sync"
+	"time"
+)
+
+type key struct {
+	conversationID int64
+	userID         int64
+}
+
+type entry struct {
+	members  []int64 // кому рассылали начало, им же уйдёт окончание
+	lastSent time.Time
+	deadline time.Time // когда индикатор погаснет без обновления
+	timer    *time.Timer
+}
+
+// Tracker — активные индикаторы набора.
+// Начало набора пересылается не чаще раза в throttle; индикатор без обновления
+// дольше ttl гаснет сам, и вызывается onExpire.
+type Tracker struct {
+	throttle time.Duration
+	ttl      time.Duration
+	onExpire func(conversationID, userID int64, members []int64)
+
+	mu     sync.Mutex
+	active map[key]*entry
+}
+
+// New создаёт трекер
+func New(throttle, ttl time.Duration, onExpire func(conversationID, userID int64, members []int64)) *Tracker {
+	return &Tracker{
+		throttle: throttle,
+		ttl:      ttl,
+		onExpire: onExpire,
+		active:   make(map[key]*entry),
+	}
+}
+
+// Refresh продлевает уже активный индикатор. true — с последней пересылки прошло
+// меньше throttle, пересылать снова не нужно.
+func (t *Tracker) Refresh(conversationID, userID int64) (throttled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.active[key{conversationID, userID}]
+	if !ok || time.Since(e.lastSent) >= t.throttle {
+		return false
+	}
+	e.deadline = time.Now().Add(t.ttl)
+	e.timer.Reset(t.ttl)
+	return true
+}
+
+// Start отмечает, что пользователь печатает, и что начало набора разослано members
+func (t *Tracker) Start(conversationID, userID int64, members []int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key{conversationID, userID}
+	if e, ok := t.active[k]; ok {
+		e.members = members
+		e.lastSent = time.Now()
+		e.deadline = e.lastSent.Add(t.ttl)
+		e.timer.Reset(t.ttl)
+		return
+	}
+
+	now := time.Now()
+	e := &entry{members: members, lastSent: now, deadline: now.Add(t.ttl)}
+	e.timer = time.AfterFunc(t.ttl, func() { t.expire(k, e) })
+	t.active[k] = e
+}
+
+// Stop гасит индикатор и отдаёт, кому было разослано начало. ok=false — пользователь не печатал.
+func (t *Tracker) Stop(conversationID, userID int64) (members []int64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key{conversationID, userID}
+	e, ok := t.active[k]
+	if !ok {
+		return nil, false
+	}
+	e.timer.Stop()
+	delete(t.active, k)
+	return e.members, true
+}
+
+func (t *Tracker) expire(k key, e *entry) {
+	t.mu.Lock()
+	// Пока таймер срабатывал, индикатор могли остановить, начать заново
+	// или продлить (тогда таймер уже перезапущен)
+	if t.active[k] != e || time.Now().Before(e.deadline) {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.active, k)
+	t.mu.Unlock()
+
+	t.onExpire(k.conversationID, k.userID, e.members)
+}
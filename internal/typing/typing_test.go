@@ -0,0 +1,139 @@
+package typing
+
+import (
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type expiry struct {
+	conversationID, userID int64
+	members                []int64
+}
+
+func newTestTracker(throttle, ttl time.Duration) (*Tracker, chan expiry) {
+	expired := make(chan expiry, 16)
+	return New(throttle, ttl, func(conversationID, userID int64, members []int64) {
+		expired <- expiry{conversationID, userID, members}
+	}), expired
+}
+
+func TestRefreshThrottled(t *testing.T) {
+	tr, _ := newTestTracker(100*time.Millisecond, time.Hour)
+
+	// Неактивный индикатор не продлевается — нужно разослать начало
+	if tr.Refresh(1, 10) {
+		t.Fatal("expected Refresh of an inactive indicator to ask for Start")
+	}
+
+	tr.Start(1, 10, []int64{20})
+	if !tr.Refresh(1, 10) {
+		t.Fatal("expected Refresh within throttle not to resend")
+	}
+	if tr.Refresh(1, 11) || tr.Refresh(2, 10) {
+		t.Fatal("indicators of other users and conversations are independent")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if tr.Refresh(1, 10) {
+		t.Fatal("expected Refresh after throttle to ask for a resend")
+	}
+
+	// Повторный Start сбрасывает throttle
+	tr.Start(1, 10, []int64{20})
+	if !tr.Refresh(1, 10) {
+		t.Fatal("expected Refresh right after Start to be throttled")
+	}
+}
+
+func TestExpireFiresOnce(t *testing.T) {
+	tr, expired := newTestTracker(time.Hour, 50*time.Millisecond)
+
+	tr.Start(1, 10, []int64{20, 30})
+
+	select {
+	case e := <-expired:
+		if e.conversationID != 1 || e.userID != 10 || !slices.Equal(e.members, []int64{20, 30}) {
+			t.Fatalf("unexpected expiry %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("indicator did not expire")
+	}
+
+	select {
+	case e := <-expired:
+		t.Fatalf("unexpected second expiry %+v", e)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	if _, ok := tr.Stop(1, 10); ok {
+		t.Fatal("expected expired indicator to be removed")
+	}
+}
+
+// Продление отодвигает срок: индикатор гаснет по последнему обновлению
+func TestRefreshPostponesExpiry(t *testing.T) {
+	tr, expired := newTestTracker(time.Hour, 100*time.Millisecond)
+
+	started := time.Now()
+	tr.Start(1, 10, nil)
+	for range 4 {
+		time.Sleep(50 * time.Millisecond)
+		if !tr.Refresh(1, 10) {
+			t.Fatal("expected Refresh to extend the indicator")
+		}
+	}
+	refreshed := time.Now()
+
+	select {
+	case <-expired:
+		if time.Since(refreshed) < 100*time.Millisecond {
+			t.Fatalf("expired %v after the last refresh, before ttl", time.Since(refreshed))
+		}
+		if time.Since(started) < 300*time.Millisecond {
+			t.Fatal("expired before the refreshed deadline")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("indicator did not expire")
+	}
+}
+
+func TestStopCancelsExpiry(t *testing.T) {
+	var calls atomic.Int32
+	tr := New(time.Hour, 50*time.Millisecond, func(int64, int64, []int64) { calls.Add(1) })
+
+	tr.Start(1, 10, []int64{20})
+	members, ok := tr.Stop(1, 10)
+	if !ok || !slices.Equal(members, []int64{20}) {
+		t.Fatalf("Stop: got %v, %v", members, ok)
+	}
+	if _, ok := tr.Stop(1, 10); ok {
+		t.Fatal("expected second Stop to report no indicator")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("expected no expiry after Stop, got %d", n)
+	}
+}
+
+// Таймер старого индикатора не гасит новый, начатый после Stop
+func TestExpireIgnoresReplacedEntry(t *testing.T) {
+	tr, expired := newTestTracker(time.Hour, 100*time.Millisecond)
+
+	tr.Start(1, 10, []int64{20})
+	time.Sleep(60 * time.Millisecond)
+	tr.Stop(1, 10)
+	tr.Start(1, 10, []int64{30})
+	restarted := time.Now()
+
+	select {
+	case e := <-expired:
+		if time.Since(restarted) < 100*time.Millisecond || !slices.Equal(e.members, []int64{30}) {
+			t.Fatalf("restarted indicator expired early or with stale members: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("indicator did not expire")
+	}
+}
@@ -1,7 +1,7 @@
 -- Создание таблицы users для хранения информации о пользователях
+-- (способы входа хранятся отдельно, в user_identities — см. 006)
 CREATE TABLE IF NOT EXISTS users (
     id BIGSERIAL PRIMARY KEY,
-    google_id VARCHAR(255) UNIQUE NOT NULL,
     email VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
     picture TEXT,
@@ -9,9 +9,5 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
 );
 
--- Индекс для быстрого поиска по google_id
-CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
-
 -- Индекс для быстрого поиска по email
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-
@@ -0,0 +1,20 @@
+-- Сессии: одна запись на каждое устройство/клиент, с которого пользователь залогинился.
+-- Все выданные access- и refresh-токены ссылаются на сессию, отзыв сессии разлогинивает устройство.
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_name VARCHAR(255) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Индекс для списка устройств пользователя
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Каждое семейство refresh-токенов принадлежит одной сессии
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id BIGINT REFERENCES sessions(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
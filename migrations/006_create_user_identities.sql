@@ -0,0 +1,32 @@
+-- Способы входа пользователя: одна запись на пару (провайдер, subject у провайдера).
+-- Заменяет колонку users.google_id.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+-- Индекс для списка способов входа пользователя
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Переносим существующие google_id в user_identities и удаляем колонку (для баз, созданных до 006)
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'google_id'
+    ) THEN
+        INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+        SELECT id, 'google', google_id, email, created_at
+        FROM users
+        WHERE google_id IS NOT NULL AND google_id <> ''
+        ON CONFLICT (provider, subject) DO NOTHING;
+
+        ALTER TABLE users DROP COLUMN google_id;
+    END IF;
+END
+$$;
@@ -0,0 +1,20 @@
+-- TOTP второй фактор. Запись появляется при начале подключения (enroll),
+-- фактор считается включённым только после подтверждения кодом (confirmed_at).
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    -- последний принятый шаг TOTP: код нельзя использовать повторно
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Одноразовые коды восстановления (храним только хеши)
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
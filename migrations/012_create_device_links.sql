@@ -0,0 +1,16 @@
+-- Вход на новом устройстве по QR-коду: новое устройство показывает код,
+-- уже залогиненное устройство его подтверждает. Храним только хеши кода
+-- (из QR) и wait-токена (его знает только ожидающее устройство).
+CREATE TABLE IF NOT EXISTS device_links (
+    id BIGSERIAL PRIMARY KEY,
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    wait_token_hash VARCHAR(64) UNIQUE NOT NULL,
+    client_name VARCHAR(255) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    approved_by BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    approved_at TIMESTAMP WITH TIME ZONE,
+    consumed_at TIMESTAMP WITH TIME ZONE
+);
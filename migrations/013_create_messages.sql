@@ -0,0 +1,33 @@
+-- Переписки. Пока только личные (direct): у каждой пары пользователей ровно одна,
+-- direct_key = "<меньший id>:<больший id>" не даёт создать вторую.
+CREATE TABLE IF NOT EXISTS conversations (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL DEFAULT 'direct',
+    direct_key VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    -- последнее сообщение, для сортировки списка переписок
+    last_message_id BIGINT,
+    last_message_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id BIGINT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+-- Индекс для списка переписок пользователя
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members(user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+-- Индекс для истории переписки (keyset-пагинация по created_at, id)
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON messages(conversation_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);
@@ -0,0 +1,5 @@
+-- До какого сообщения участник прочитал переписку (для счётчика непрочитанных)
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS last_read_message_id BIGINT NOT NULL DEFAULT 0;
+
+-- Индекс для подсчёта непрочитанных: сообщения переписки после заданного id
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id_id ON messages(conversation_id, id);
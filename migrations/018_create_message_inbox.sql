@@ -0,0 +1,28 @@
+-- Лента пользователя для офлайн-синхронизации: каждое сообщение его переписок
+-- получает следующий номер seq (монотонно растёт в пределах пользователя).
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS message_inbox (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_inbox_message_id ON message_inbox(message_id);
+
+-- До какого seq устройство (сессия или персональный токен) подтвердило получение
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS acked_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE personal_access_tokens ADD COLUMN IF NOT EXISTS acked_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Сообщения, отправленные до появления ленты, нумеруем по порядку (один раз, пока лента пуста)
+INSERT INTO message_inbox (user_id, seq, message_id)
+SELECT cm.user_id, ROW_NUMBER() OVER (PARTITION BY cm.user_id ORDER BY m.id), m.id
+FROM conversation_members cm
+JOIN messages m ON m.conversation_id = cm.conversation_id
+WHERE NOT EXISTS (SELECT 1 FROM message_inbox);
+
+UPDATE users u
+SET last_seq = i.max_seq
+FROM (SELECT user_id, MAX(seq) AS max_seq FROM message_inbox GROUP BY user_id) i
+WHERE u.id = i.user_id AND u.last_seq < i.max_seq;
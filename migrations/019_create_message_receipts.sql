@@ -0,0 +1,14 @@
+-- Отметки о доставке и прочтении: строка на сообщение и получателя
+-- (у отправителя своих отметок нет)
+CREATE TABLE IF NOT EXISTS message_receipts (
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delivered_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    read_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_receipts_user_id ON message_receipts(user_id);
+
+-- До какого seq ленты пользователь получил сообщения хотя бы на одном устройстве
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delivered_seq BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Когда пользователь последний раз был в сети (пишется при закрытии последнего сокета)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE;
+
+-- Настройка приватности: не показывать другим last_seen_at
+ALTER TABLE users ADD COLUMN IF NOT EXISTS hide_last_seen BOOLEAN NOT NULL DEFAULT FALSE;
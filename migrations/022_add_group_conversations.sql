@@ -0,0 +1,12 @@
+-- Групповые переписки: название, аватар, кто создал, закреплённое сообщение
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS title VARCHAR(255);
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS avatar TEXT;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS created_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS pinned_message_id BIGINT REFERENCES messages(id) ON DELETE SET NULL;
+
+-- Роль участника группы: owner, admin или member (в личных переписках — member)
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'member';
+
+-- Служебные сообщения ("Alice added Bob"): kind = 'system', в system — само событие
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'text';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS system JSONB;
@@ -0,0 +1,27 @@
+-- Ссылки-приглашения в группы. Токен хранится как есть: ссылку админы
+-- пересылают и смотрят повторно, это не секрет для входа в аккаунт.
+CREATE TABLE IF NOT EXISTS group_invites (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    max_uses INTEGER,
+    uses INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_invites_conversation_id ON group_invites(conversation_id);
+
+-- Заявки на вступление по ссылкам с одобрением админа
+CREATE TABLE IF NOT EXISTS group_join_requests (
+    conversation_id BIGINT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invite_id BIGINT REFERENCES group_invites(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_join_requests_user_id ON group_join_requests(user_id);
@@ -0,0 +1,22 @@
+-- Редактирование и удаление сообщений. У удалённого для всех content пустой,
+-- deleted_at — когда удалено.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- История правок: прежний текст сообщения до каждой правки
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message_id ON message_edits(message_id);
+
+-- Сообщения, удалённые пользователем только у себя
+CREATE TABLE IF NOT EXISTS message_hidden (
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hidden_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, message_id)
+);